@@ -3,14 +3,21 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"html/template"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gocarina/gocsv"
@@ -68,31 +75,13 @@ type VotePlans struct {
 	Proposals           []VoteProposal `json:"proposals"`
 }
 
-// TallyOptions total 16 choices available (0-15)
-type TallyOptions struct {
-	// TODO: this is ...
-	Tally00 uint `json:"tally_0"  csv:"tally_0_BLANK"`
-	Tally01 uint `json:"tally_1"  csv:"tally_1_YES"`
-	Tally02 uint `json:"tally_2"  csv:"tally_2_NO"`
-	Tally03 uint `json:"-"        csv:"-"`
-	Tally04 uint `json:"-"        csv:"-"`
-	Tally05 uint `json:"-"        csv:"-"`
-	Tally06 uint `json:"-"        csv:"-"`
-	Tally07 uint `json:"-"        csv:"-"`
-	Tally08 uint `json:"-"        csv:"-"`
-	Tally09 uint `json:"-"        csv:"-"`
-	Tally10 uint `json:"-"        csv:"-"`
-	Tally11 uint `json:"-"        csv:"-"`
-	Tally12 uint `json:"-"        csv:"-"`
-	Tally13 uint `json:"-"        csv:"-"`
-	Tally14 uint `json:"-"        csv:"-"`
-	Tally15 uint `json:"-"        csv:"-"`
-}
-
+// ProposalsResult is the proposal with the votes cast and the tally result
+// of each vote option (by option name), empty when not tallied yet.
 type ProposalsResult struct {
 	loader.ProposalData
-	VotesCast uint `json:"votes_cast" csv:"votes_cast"`
-	TallyOptions
+	VotesCast uint            `json:"votes_cast" csv:"votes_cast"`
+	Tally     map[string]uint `json:"tally"      csv:"-"` // one "tally_<option>" CSV column each
+	Funding   *FundingResult  `json:"funding"    csv:"-"` // funding decision, when enabled
 }
 
 func getData(client *http.Client, u *url.URL, dst interface{}) error {
@@ -143,43 +132,697 @@ func httpGet(client *http.Client, u string) ([]byte, error) {
 }
 
 func getTallyResults(tally TallyResult, proposal *ProposalsResult) {
+	if len(tally.Result.Results) == 0 {
+		return
+	}
+	proposal.Tally = make(map[string]uint, len(tally.Result.Results))
 	for r, tr := range tally.Result.Results {
-		switch r {
-		// TODO: this is ...
-		case 0:
-			proposal.TallyOptions.Tally00 = tr
-		case 1:
-			proposal.TallyOptions.Tally01 = tr
-		case 2:
-			proposal.TallyOptions.Tally02 = tr
-		case 3:
-			proposal.TallyOptions.Tally03 = tr
-		case 4:
-			proposal.TallyOptions.Tally04 = tr
-		case 5:
-			proposal.TallyOptions.Tally05 = tr
-		case 6:
-			proposal.TallyOptions.Tally06 = tr
-		case 7:
-			proposal.TallyOptions.Tally07 = tr
-		case 8:
-			proposal.TallyOptions.Tally08 = tr
-		case 9:
-			proposal.TallyOptions.Tally09 = tr
-		case 10:
-			proposal.TallyOptions.Tally10 = tr
-		case 11:
-			proposal.TallyOptions.Tally11 = tr
-		case 12:
-			proposal.TallyOptions.Tally12 = tr
-		case 13:
-			proposal.TallyOptions.Tally13 = tr
-		case 14:
-			proposal.TallyOptions.Tally14 = tr
-		case 15:
-			proposal.TallyOptions.Tally15 = tr
+		proposal.Tally[optionName(proposal.VoteOptions, r)] = tr
+	}
+}
+
+// optionName returns the name of the vote option with index idx,
+// the index itself when the proposal options don't name it.
+func optionName(options loader.ChainVoteOptions, idx int) string {
+	for name, i := range options {
+		if int(i) == idx {
+			return name
+		}
+	}
+	return strconv.Itoa(idx)
+}
+
+// tallyColumns returns the vote options names of all the proposals, ordered by option index.
+func tallyColumns(proposals []ProposalsResult) []string {
+	index := make(map[string]int)
+	for i := range proposals {
+		for name, idx := range proposals[i].VoteOptions {
+			if cur, ok := index[name]; !ok || int(idx) < cur {
+				index[name] = int(idx)
+			}
+		}
+		// options tallied but not named
+		for name := range proposals[i].Tally {
+			if _, ok := index[name]; !ok {
+				idx, _ := strconv.Atoi(name)
+				index[name] = idx
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(index))
+	for name := range index {
+		columns = append(columns, name)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if index[columns[i]] != index[columns[j]] {
+			return index[columns[i]] < index[columns[j]]
+		}
+		return columns[i] < columns[j]
+	})
+	return columns
+}
+
+// marshalResultCsv writes the proposals results as CSV to w,
+// with one "tally_<option>" column for each vote option found.
+func marshalResultCsv(proposals []ProposalsResult, w io.Writer) error {
+	data, err := gocsv.MarshalString(&proposals)
+	if err != nil {
+		return err
+	}
+	rows, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	columns := tallyColumns(proposals)
+	for i := range rows {
+		for _, name := range columns {
+			if i == 0 {
+				rows[i] = append(rows[i], "tally_"+name)
+				continue
+			}
+			cell := ""
+			if tr, ok := proposals[i-1].Tally[name]; ok {
+				cell = strconv.FormatUint(uint64(tr), 10)
+			}
+			rows[i] = append(rows[i], cell)
+		}
+		switch {
+		case i == 0 && len(proposals) > 0 && proposals[0].Funding != nil:
+			rows[i] = append(rows[i], fundingColumns...)
+		case i > 0 && proposals[i-1].Funding != nil:
+			rows[i] = append(rows[i], proposals[i-1].Funding.csvRow()...)
+		}
+	}
+
+	return csv.NewWriter(w).WriteAll(rows)
+}
+
+// OptionResult is the tally result of a vote option.
+type OptionResult struct {
+	Option string `json:"option"`
+	Votes  uint   `json:"votes"`
+}
+
+// ProposalResultJSON is the JSON result of a proposal.
+type ProposalResultJSON struct {
+	Proposal  loader.ProposalData `json:"proposal"`
+	VotesCast uint                `json:"votes_cast"`
+	Tally     []OptionResult      `json:"tally"`             // ordered by option index, empty when not tallied yet
+	Funding   *FundingResult      `json:"funding,omitempty"` // funding decision, when enabled
+}
+
+// ChallengeResult is the results section of a challenge.
+type ChallengeResult struct {
+	ID        uint32
+	Title     string
+	Columns   []string // vote options
+	Proposals []ProposalsResult
+	Budget    uint64 // ADA, rewards total
+	Left      uint64 // ADA, budget left after the funding
+}
+
+// resultFormats are the supported output formats, with the default result file.
+var resultFormats = map[string]string{
+	"csv":  "TallyResult.csv",
+	"json": "TallyResult.json",
+	"md":   "TallyResult.md",
+	"html": "TallyResult.html",
+}
+
+// optionResults returns the tally result of each vote option of p, ordered by option index.
+func optionResults(p ProposalsResult) []OptionResult {
+	results := make([]OptionResult, 0, len(p.Tally))
+	for _, name := range tallyColumns([]ProposalsResult{p}) {
+		if tr, ok := p.Tally[name]; ok {
+			results = append(results, OptionResult{Option: name, Votes: tr})
+		}
+	}
+	return results
+}
+
+// challengeResults groups the proposals by challenge, ordered by challenge id.
+// Challenges not found in challenges are named after their id.
+// With the funding decisions the proposals are ordered by rank.
+func challengeResults(proposals []ProposalsResult, challenges []loader.ChallengeData) []ChallengeResult {
+	titles := make(map[uint32]string, len(challenges))
+	budgets := make(map[uint32]uint64, len(challenges))
+	for i := range challenges {
+		titles[challenges[i].ID] = challenges[i].Title
+		budgets[challenges[i].ID] = challenges[i].RewardsTotal
+	}
+
+	byID := make(map[uint32]*ChallengeResult)
+	ids := make([]uint32, 0)
+	for i := range proposals {
+		id := proposals[i].ChallengeID
+		cr, ok := byID[id]
+		if !ok {
+			cr = &ChallengeResult{ID: id, Title: titles[id], Budget: budgets[id], Left: budgets[id]}
+			if cr.Title == "" {
+				cr.Title = "Challenge " + strconv.FormatUint(uint64(id), 10)
+			}
+			byID[id] = cr
+			ids = append(ids, id)
+		}
+		cr.Proposals = append(cr.Proposals, proposals[i])
+		if f := proposals[i].Funding; f != nil && f.Funded {
+			cr.Left -= f.Cost
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	sections := make([]ChallengeResult, 0, len(ids))
+	for _, id := range ids {
+		cr := byID[id]
+		cr.Columns = tallyColumns(cr.Proposals)
+		sort.SliceStable(cr.Proposals, func(i, j int) bool {
+			fi, fj := cr.Proposals[i].Funding, cr.Proposals[j].Funding
+			if fi == nil || fj == nil || fi.Rank == fj.Rank {
+				return false
+			}
+			return fj.Rank == 0 || (fi.Rank != 0 && fi.Rank < fj.Rank)
+		})
+		sections = append(sections, *cr)
+	}
+	return sections
+}
+
+// marshalResultJSON writes the proposals results as JSON to w.
+func marshalResultJSON(proposals []ProposalsResult, w io.Writer) error {
+	results := make([]ProposalResultJSON, len(proposals))
+	for i := range proposals {
+		results[i] = ProposalResultJSON{
+			Proposal:  proposals[i].ProposalData,
+			VotesCast: proposals[i].VotesCast,
+			Tally:     optionResults(proposals[i]),
+			Funding:   proposals[i].Funding,
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// ada returns the lovelace amount in ADA, rounded up so a requested amount is never undervalued.
+func ada(l loader.Lovelace) uint64 {
+	return (uint64(l) + 999_999) / 1_000_000
+}
+
+// mdEscape escapes the markdown table cell s.
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "<", "&lt;", ">", "&gt;", "\r", " ", "\n", " ").Replace(s)
+}
+
+// mdURL percent-encodes the characters of u that would break a markdown link target in a table cell.
+func mdURL(u string) string {
+	var b strings.Builder
+	for i := 0; i < len(u); i++ {
+		if c := u[i]; c <= ' ' || c == 0x7f || strings.IndexByte("()<>[]|\\\"'`", c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// marshalResultMd writes the proposals results as markdown to w, one table per challenge.
+// With the funding summary the proposals have their funding status, ordered by rank.
+func marshalResultMd(proposals []ProposalsResult, challenges []loader.ChallengeData, fund loader.FundData, summary *FundingSummary, w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s - Tally Result\n", mdEscape(fund.Name))
+	if summary != nil {
+		fmt.Fprintf(&b, "\nFunded proposals: %d - allocated: %d ADA", summary.Funded, summary.Allocated)
+		if summary.Rules.FundBudget > 0 {
+			fmt.Fprintf(&b, " - fund budget: %d ADA, remaining: %d ADA", summary.Rules.FundBudget, summary.BudgetLeft)
+		}
+		fmt.Fprintf(&b, " - approval threshold: %v%%\n", summary.Rules.ApprovalThreshold)
+	}
+	for _, cr := range challengeResults(proposals, challenges) {
+		fmt.Fprintf(&b, "\n## %s\n\n", mdEscape(cr.Title))
+		if summary != nil {
+			fmt.Fprintf(&b, "Budget: %d ADA - remaining: %d ADA\n\n", cr.Budget, cr.Left)
+		}
+
+		b.WriteString("| Proposal | Requested funds (ADA) | Votes cast |")
+		for _, name := range cr.Columns {
+			fmt.Fprintf(&b, " %s |", mdEscape(name))
+		}
+		if summary != nil {
+			b.WriteString(" Rank | Net yes | Status | Reason |")
+		}
+		b.WriteString("\n|---|--:|--:|")
+		b.WriteString(strings.Repeat("--:|", len(cr.Columns)))
+		if summary != nil {
+			b.WriteString("--:|--:|---|---|")
+		}
+		b.WriteString("\n")
+
+		for _, p := range cr.Proposals {
+			title := mdEscape(p.Title)
+			if p.ProposalURL != "" {
+				title = "[" + title + "](" + mdURL(p.ProposalURL) + ")"
+			}
+			fmt.Fprintf(&b, "| %s | %d | %d |", title, ada(p.Funds), p.VotesCast)
+			for _, name := range cr.Columns {
+				if tr, ok := p.Tally[name]; ok {
+					fmt.Fprintf(&b, " %d |", tr)
+				} else {
+					b.WriteString(" |")
+				}
+			}
+			if f := p.Funding; f != nil {
+				fmt.Fprintf(&b, " %d | %d | %s | %s |", f.Rank, f.NetYes, f.status(), mdEscape(f.Reason))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// htmlBar is a vote option bar of the HTML report.
+type htmlBar struct {
+	Option  string
+	Votes   uint
+	Percent float64
+}
+
+var resultHTML = template.Must(template.New("result").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Fund.Name}} - Tally Result</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.4em; text-align: left; vertical-align: top; }
+td.num { text-align: right; white-space: nowrap; }
+.bar { display: flex; align-items: center; margin: 0.1em 0; }
+.bar .label { width: 6em; font-size: 0.85em; }
+.bar .fill { height: 0.9em; background: #3366cc; margin-right: 0.4em; }
+.bar .votes { font-size: 0.85em; color: #555; }
+.funded { color: #1a7f37; font-weight: bold; }
+.not-funded { color: #b42318; }
+</style>
+</head>
+<body>
+<h1>{{.Fund.Name}} - Tally Result</h1>
+{{with .Summary}}<p>Funded proposals: {{.Funded}} - allocated: {{.Allocated}} ADA{{if .Rules.FundBudget}} - fund budget: {{.Rules.FundBudget}} ADA, remaining: {{.BudgetLeft}} ADA{{end}} - approval threshold: {{.Rules.ApprovalThreshold}}%</p>{{end}}
+{{range .Challenges}}
+<h2>{{.Title}}</h2>
+{{if $.Summary}}<p>Budget: {{.Budget}} ADA - remaining: {{.Left}} ADA</p>{{end}}
+<table>
+<tr><th>Proposal</th><th>Requested funds (ADA)</th><th>Votes cast</th><th>Tally</th>{{if $.Summary}}<th>Rank</th><th>Status</th>{{end}}</tr>
+{{range .Proposals}}<tr>
+<td>{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</td>
+<td class="num">{{.Funds}}</td>
+<td class="num">{{.VotesCast}}</td>
+<td>{{range .Bars}}<div class="bar"><span class="label">{{.Option}}</span><span class="fill" style="width: {{printf "%.1f" .Percent}}%"></span><span class="votes">{{.Votes}}</span></div>{{else}}not tallied yet{{end}}</td>
+{{with .Funding}}<td class="num">{{.Rank}}</td>
+<td><span class="{{if .Funded}}funded{{else}}not-funded{{end}}">{{.Status}}</span><br>{{.Reason}}</td>
+{{end}}</tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// marshalResultHTML writes the proposals results as a self contained HTML report to w,
+// one section per challenge, with the vote options bar charts.
+// With the funding summary the proposals have their funding status, ordered by rank.
+func marshalResultHTML(proposals []ProposalsResult, challenges []loader.ChallengeData, fund loader.FundData, summary *FundingSummary, w io.Writer) error {
+	type htmlFunding struct {
+		*FundingResult
+		Status string
+	}
+	type htmlProposal struct {
+		Title     string
+		URL       string
+		Funds     uint64 // ADA
+		VotesCast uint
+		Bars      []htmlBar
+		Funding   *htmlFunding
+	}
+	type htmlChallenge struct {
+		Title     string
+		Budget    uint64
+		Left      uint64
+		Proposals []htmlProposal
+	}
+
+	sections := challengeResults(proposals, challenges)
+	data := struct {
+		Fund       loader.FundData
+		Summary    *FundingSummary
+		Challenges []htmlChallenge
+	}{Fund: fund, Summary: summary, Challenges: make([]htmlChallenge, len(sections))}
+
+	for i, cr := range sections {
+		data.Challenges[i].Title = cr.Title
+		data.Challenges[i].Budget, data.Challenges[i].Left = cr.Budget, cr.Left
+		for _, p := range cr.Proposals {
+			hp := htmlProposal{Title: p.Title, URL: p.ProposalURL, Funds: ada(p.Funds), VotesCast: p.VotesCast}
+			if p.Funding != nil {
+				hp.Funding = &htmlFunding{FundingResult: p.Funding, Status: p.Funding.status()}
+			}
+
+			results := optionResults(p)
+			var total uint
+			for _, r := range results {
+				total += r.Votes
+			}
+			for _, r := range results {
+				bar := htmlBar{Option: r.Option, Votes: r.Votes}
+				if total > 0 {
+					bar.Percent = float64(r.Votes) * 100 / float64(total)
+				}
+				hp.Bars = append(hp.Bars, bar)
+			}
+			data.Challenges[i].Proposals = append(data.Challenges[i].Proposals, hp)
+		}
+	}
+
+	return resultHTML.Execute(w, data)
+}
+
+// FundingRules are the rules deciding which proposals are funded.
+type FundingRules struct {
+	ApprovalThreshold float64 // net yes votes (yes - no) needed, percentage of the voting power
+	VotingPower       uint64  // total voting power, 0 means the votes of each proposal
+	FundBudget        uint64  // ADA, 0 means only the challenges budget applies
+}
+
+// FundingResult is the funding decision of a proposal.
+// The CSV columns are added only with the funding (fundingColumns), gocsv would flatten them always.
+type FundingResult struct {
+	Rank                uint   `json:"rank"                  csv:"-"` // by net yes votes within the challenge, 0 when not tallied
+	NetYes              int64  `json:"net_yes"               csv:"-"`
+	Approved            bool   `json:"approved"              csv:"-"`
+	Funded              bool   `json:"funded"                csv:"-"`
+	Reason              string `json:"reason"                csv:"-"`
+	Cost                uint64 `json:"cost"                  csv:"-"` // ADA
+	ChallengeBudgetLeft uint64 `json:"challenge_budget_left" csv:"-"` // ADA, after the decision
+	FundBudgetLeft      uint64 `json:"fund_budget_left"      csv:"-"` // ADA, after the decision (0 when no fund budget)
+}
+
+// FundingSummary is the funding outcome of the whole fund.
+type FundingSummary struct {
+	Rules      FundingRules
+	Funded     int
+	Allocated  uint64 // ADA
+	BudgetLeft uint64 // ADA, fund budget left (0 when no fund budget)
+}
+
+// voteOption returns the votes of the option named name (case insensitive).
+func voteOption(tally map[string]uint, name string) uint {
+	for option, votes := range tally {
+		if strings.EqualFold(option, name) {
+			return votes
+		}
+	}
+	return 0
+}
+
+// applyFunding decides the proposals funding, setting their FundingResult.
+// Within each challenge the tallied proposals are ranked by net yes votes (then yes votes),
+// and the approved ones are funded in rank order while the challenge budget (rewards_total)
+// and the fund budget allow it, skipping the ones over budget (greedy).
+// The fund budget is shared by the challenges in challenge id order.
+func applyFunding(proposals []ProposalsResult, challenges []loader.ChallengeData, rules FundingRules) FundingSummary {
+	summary := FundingSummary{Rules: rules, BudgetLeft: rules.FundBudget}
+
+	budgets := make(map[uint32]uint64, len(challenges))
+	for i := range challenges {
+		budgets[challenges[i].ID] = challenges[i].RewardsTotal
+	}
+
+	byChallenge := make(map[uint32][]*ProposalsResult)
+	ids := make([]uint32, 0)
+	for i := range proposals {
+		p := &proposals[i]
+		p.Funding = &FundingResult{Cost: ada(p.Funds)}
+		if _, ok := byChallenge[p.ChallengeID]; !ok {
+			ids = append(ids, p.ChallengeID)
+		}
+		byChallenge[p.ChallengeID] = append(byChallenge[p.ChallengeID], p)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		ranked := make([]*ProposalsResult, 0, len(byChallenge[id]))
+		for _, p := range byChallenge[id] {
+			if len(p.Tally) == 0 {
+				p.Funding.Reason = "not tallied yet"
+				continue
+			}
+			yes, no := voteOption(p.Tally, "yes"), voteOption(p.Tally, "no")
+			p.Funding.NetYes = int64(yes) - int64(no)
+
+			power := rules.VotingPower
+			if power == 0 {
+				for _, votes := range p.Tally {
+					power += uint64(votes)
+				}
+			}
+			threshold := rules.ApprovalThreshold / 100 * float64(power)
+			p.Funding.Approved = p.Funding.NetYes > 0 && float64(p.Funding.NetYes) >= threshold
+			if !p.Funding.Approved {
+				p.Funding.Reason = fmt.Sprintf("below approval threshold (net yes %d < %.0f)", p.Funding.NetYes, math.Max(math.Ceil(threshold), 1))
+			}
+			ranked = append(ranked, p)
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			if ranked[i].Funding.NetYes != ranked[j].Funding.NetYes {
+				return ranked[i].Funding.NetYes > ranked[j].Funding.NetYes
+			}
+			return voteOption(ranked[i].Tally, "yes") > voteOption(ranked[j].Tally, "yes")
+		})
+
+		left := budgets[id]
+		for r, p := range ranked {
+			f := p.Funding
+			f.Rank = uint(r + 1)
+			switch {
+			case !f.Approved:
+			case f.Cost > left:
+				f.Reason = fmt.Sprintf("over challenge budget (%d left)", left)
+			case rules.FundBudget > 0 && f.Cost > summary.BudgetLeft:
+				f.Reason = fmt.Sprintf("over fund budget (%d left)", summary.BudgetLeft)
+			default:
+				f.Funded, f.Reason = true, "funded"
+				left -= f.Cost
+				if rules.FundBudget > 0 {
+					summary.BudgetLeft -= f.Cost
+				}
+				summary.Funded++
+				summary.Allocated += f.Cost
+			}
+			f.ChallengeBudgetLeft, f.FundBudgetLeft = left, summary.BudgetLeft
+		}
+		for _, p := range byChallenge[id] {
+			if p.Funding.Rank == 0 {
+				p.Funding.ChallengeBudgetLeft, p.Funding.FundBudgetLeft = left, summary.BudgetLeft
+			}
+		}
+	}
+
+	return summary
+}
+
+// fundingColumns are the CSV columns of the funding decision.
+var fundingColumns = []string{"funding_rank", "funding_net_yes", "funding_approved", "funded", "funding_reason", "funding_cost", "challenge_budget_left", "fund_budget_left"}
+
+// status is the funding status of the proposal.
+func (f *FundingResult) status() string {
+	if f.Funded {
+		return "funded"
+	}
+	return "not funded"
+}
+
+// csvRow returns the funding columns values.
+func (f *FundingResult) csvRow() []string {
+	return []string{
+		strconv.FormatUint(uint64(f.Rank), 10),
+		strconv.FormatInt(f.NetYes, 10),
+		strconv.FormatBool(f.Approved),
+		strconv.FormatBool(f.Funded),
+		f.Reason,
+		strconv.FormatUint(f.Cost, 10),
+		strconv.FormatUint(f.ChallengeBudgetLeft, 10),
+		strconv.FormatUint(f.FundBudgetLeft, 10),
+	}
+}
+
+// verifyTally returns the inconsistencies between the station proposals and the voteplans proposals:
+// proposals missing from the voteplans, voteplans proposals unknown to the station,
+// chain proposal id and vote options mismatches, tallies both public and private,
+// and tallies without votes cast (or votes cast without tally).
+func verifyTally(proposals []ProposalsResult, votePlans []VotePlans) []string {
+	issues := make([]string, 0)
+
+	type chainKey struct {
+		votePlanID string
+		index      uint8
+	}
+	known := make(map[chainKey]bool, len(proposals))
+	for i := range proposals {
+		known[chainKey{proposals[i].VotePlanID, proposals[i].ChainProposal.Index}] = true
+	}
+
+	chain := make(map[chainKey]*VoteProposal)
+	for x := range votePlans {
+		for y := range votePlans[x].Proposals {
+			vp := &votePlans[x].Proposals[y]
+			key := chainKey{votePlans[x].ID, vp.Index}
+			chain[key] = vp
+
+			if !known[key] {
+				issues = append(issues, fmt.Sprintf("voteplan [%s] proposal [%d] (%s) unknown to the station", key.votePlanID, key.index, vp.ProposalID))
+			}
+
+			public, private := vp.Tally.Public.Result.Results, vp.Tally.Private.State.Decrypted.Result.Results
+			if len(public) > 0 && len(private) > 0 {
+				issues = append(issues, fmt.Sprintf("voteplan [%s] proposal [%d] has both public and private tally", key.votePlanID, key.index))
+			}
+			results := public
+			if len(results) == 0 {
+				results = private
+			}
+			options := int(vp.Options.End) - int(vp.Options.Start)
+			if len(results) > 0 && len(results) != options {
+				issues = append(issues, fmt.Sprintf("voteplan [%s] proposal [%d] has [%d] tally results for [%d] options", key.votePlanID, key.index, len(results), options))
+			}
+			var sum uint
+			for _, tr := range results {
+				sum += tr
+			}
+			if len(results) > 0 && (sum == 0) != (vp.VotesCast == 0) {
+				issues = append(issues, fmt.Sprintf("voteplan [%s] proposal [%d] tally results sum [%d] with [%d] votes cast", key.votePlanID, key.index, sum, vp.VotesCast))
+			}
+		}
+	}
+
+	for i := range proposals {
+		p := &proposals[i]
+		vp, ok := chain[chainKey{p.VotePlanID, p.ChainProposal.Index}]
+		if !ok {
+			issues = append(issues, fmt.Sprintf("proposal [%s] missing from voteplan [%s] at index [%d]", p.ID, p.VotePlanID, p.ChainProposal.Index))
+			continue
+		}
+		if p.ChainProposal.ExternalID != vp.ProposalID {
+			issues = append(issues, fmt.Sprintf("proposal [%s] chain id [%s] does not match voteplan [%s] index [%d] id [%s]", p.ID, p.ChainProposal.ExternalID, p.VotePlanID, vp.Index, vp.ProposalID))
+		}
+		if options := int(vp.Options.End) - int(vp.Options.Start); len(p.VoteOptions) != options {
+			issues = append(issues, fmt.Sprintf("proposal [%s] has [%d] vote options, voteplan [%s] index [%d] has [%d]", p.ID, len(p.VoteOptions), p.VotePlanID, vp.Index, options))
+		}
+	}
+
+	return issues
+}
+
+// nodeVotePlans are the voteplans fetched from a node.
+type nodeVotePlans struct {
+	Node      string
+	VotePlans []VotePlans
+	Err       error
+}
+
+// fetchVotePlans fetches concurrently the voteplans from each node url.
+func fetchVotePlans(client *http.Client, urls []*url.URL) []nodeVotePlans {
+	results := make([]nodeVotePlans, len(urls))
+	var wg sync.WaitGroup
+	for i := range urls {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i].Node = urls[i].Host + urls[i].Path
+			results[i].Err = getData(client, urls[i], &results[i].VotePlans)
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// tallyResults returns the tally results of the proposal, public or private, empty when not tallied yet.
+func tallyResults(vp *VoteProposal) []uint {
+	if len(vp.Tally.Public.Result.Results) > 0 {
+		return vp.Tally.Public.Result.Results
+	}
+	return vp.Tally.Private.State.Decrypted.Result.Results
+}
+
+// equalResults reports whether the tally results a and b are the same, option by option.
+func equalResults(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
 		}
 	}
+	return true
+}
+
+// compareVotePlans returns the divergences of the node voteplans from the reference node ones:
+// proposals only on one of them, and different votes cast or tally results.
+func compareVotePlans(ref, node nodeVotePlans) []string {
+	type chainKey struct {
+		votePlanID string
+		index      uint8
+	}
+	index := func(votePlans []VotePlans) map[chainKey]*VoteProposal {
+		proposals := make(map[chainKey]*VoteProposal)
+		for x := range votePlans {
+			for y := range votePlans[x].Proposals {
+				proposals[chainKey{votePlans[x].ID, votePlans[x].Proposals[y].Index}] = &votePlans[x].Proposals[y]
+			}
+		}
+		return proposals
+	}
+	refProposals, nodeProposals := index(ref.VotePlans), index(node.VotePlans)
+
+	keys := make([]chainKey, 0, len(refProposals))
+	for key := range refProposals {
+		keys = append(keys, key)
+	}
+	for key := range nodeProposals {
+		if _, ok := refProposals[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].votePlanID != keys[j].votePlanID {
+			return keys[i].votePlanID < keys[j].votePlanID
+		}
+		return keys[i].index < keys[j].index
+	})
+
+	divergences := make([]string, 0)
+	for _, key := range keys {
+		rp, rok := refProposals[key]
+		np, nok := nodeProposals[key]
+		switch {
+		case !nok:
+			divergences = append(divergences, fmt.Sprintf("voteplan [%s] proposal [%d] missing on [%s]", key.votePlanID, key.index, node.Node))
+		case !rok:
+			divergences = append(divergences, fmt.Sprintf("voteplan [%s] proposal [%d] missing on [%s]", key.votePlanID, key.index, ref.Node))
+		default:
+			if rp.VotesCast != np.VotesCast {
+				divergences = append(divergences, fmt.Sprintf("voteplan [%s] proposal [%d] votes cast [%d] on [%s], [%d] on [%s]", key.votePlanID, key.index, rp.VotesCast, ref.Node, np.VotesCast, node.Node))
+			}
+			if rt, nt := tallyResults(rp), tallyResults(np); !equalResults(rt, nt) {
+				divergences = append(divergences, fmt.Sprintf("voteplan [%s] proposal [%d] tally %v on [%s], %v on [%s]", key.votePlanID, key.index, rt, ref.Node, nt, node.Node))
+			}
+		}
+	}
+	return divergences
 }
 
 func main() {
@@ -189,18 +832,28 @@ func main() {
 			Timeout: time.Second * 10,
 		}
 		// Data
-		votePlans []VotePlans
-		proposals []ProposalsResult
-		funds     loader.FundData
+		votePlans  []VotePlans
+		proposals  []ProposalsResult
+		funds      loader.FundData
+		challenges []loader.ChallengeData
 		// Flags
 		serviceUrl   = flag.String("service-addr", "https://servicing-station.vit.iohk.io", "Address of remote service, or file://")
-		nodeUrl      = flag.String("node-addr", "https://servicing-station.vit.iohk.io", "Address of remote service, or file://")
+		nodeUrl      = flag.String("node-addr", "https://servicing-station.vit.iohk.io", "Address of remote service, or file://. Comma separated list to check that the nodes agree on the tallies")
 		votePlansUrl = flag.String("vote-plans", "/api/v0/vote/active/plans", "Endpoint (or file path) containing  tally results from the chain, added to \"node-addr\"")
 		proposalsUrl = flag.String("proposals", "/api/v0/proposals", "Endpoint (or file path) containing proposals, added to \"service-addr\"")
 		fundsUrl     = flag.String("funds", "/api/v0/fund", "Endpoint (or file path) containing fund info, added to \"service-addr\"")
+		challUrl     = flag.String("challenges", "/api/v0/challenges", "Endpoint (or file path) containing challenges info, added to \"service-addr\". Used by the md and html formats")
 		timeout      = flag.String("http-timeout", "10s", "Http request timeout")
 		// Flags - TallyResult file
-		tallyResultFile = flag.String("result-file", "TallyResult.csv", "File name of the output result")
+		tallyResultFile = flag.String("result-file", "", "File name of the output result (default \"TallyResult.<format>\")")
+		format          = flag.String("format", "csv", "Output format of the result, [csv, json, md, html]")
+		// Flags - funding rules
+		funding           = flag.Bool("funding", false, "Decide the funded proposals, by challenge net yes votes ranking and budget")
+		approvalThreshold = flag.Float64("approval-threshold", 1, "Net yes votes (yes - no) needed for a proposal approval, percentage of the voting power")
+		votingPower       = flag.Uint64("voting-power", 0, "Total voting power used by the approval threshold (default the votes of each proposal)")
+		fundBudget        = flag.Uint64("fund-budget", 0, "Fund budget in ADA, shared by the challenges (default only the challenges budget)")
+		// Flags - verify
+		verify = flag.Bool("verify", false, "Verify the station proposals against the voteplans tallies, exit 1 on any inconsistency (no result file)")
 		// Flags - version info
 		version = flag.Bool("version", false, "Print current app version and build info")
 	)
@@ -215,23 +868,83 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Output format, with its own default result file
+	defaultFile, ok := resultFormats[*format]
+	if !ok {
+		kit.FatalOn(fmt.Errorf("unknown format [%s]", *format), "format")
+	}
+	if *tallyResultFile == "" {
+		*tallyResultFile = defaultFile
+	}
+
 	// Http timeout
 	timeoutDur, err := time.ParseDuration(*timeout)
 	kit.FatalOn(err, "http-timeout:", *timeout)
 	client.Timeout = timeoutDur
 
 	// Parse URI
-	vpUrl, err := url.ParseRequestURI(*nodeUrl + *votePlansUrl)
-	kit.FatalOn(err, "url.ParseRequestURI:", *votePlansUrl)
+	vpUrls := make([]*url.URL, 0)
+	for _, node := range strings.Split(*nodeUrl, ",") {
+		vpUrl, err := url.ParseRequestURI(strings.TrimSpace(node) + *votePlansUrl)
+		kit.FatalOn(err, "url.ParseRequestURI:", node, *votePlansUrl)
+		vpUrls = append(vpUrls, vpUrl)
+	}
 	prUrl, err := url.ParseRequestURI(*serviceUrl + *proposalsUrl)
 	kit.FatalOn(err, "url.ParseRequestURI:", *proposalsUrl)
 	fuUrl, err := url.ParseRequestURI(*serviceUrl + *fundsUrl)
 	kit.FatalOn(err, "url.ParseRequestURI:", *fundsUrl)
 
 	// Fetch Data
-	kit.FatalOn(getData(&client, vpUrl, &votePlans), "getData VotePlans")
+	nodesVotePlans := fetchVotePlans(&client, vpUrls)
+	for _, nvp := range nodesVotePlans {
+		kit.FatalOn(nvp.Err, "getData VotePlans", nvp.Node)
+	}
+	votePlans = nodesVotePlans[0].VotePlans
+
+	// Nodes consensus, forked or lagging nodes diverge from the first one
+	if len(nodesVotePlans) > 1 {
+		divergences := make([]string, 0)
+		for _, nvp := range nodesVotePlans[1:] {
+			divergences = append(divergences, compareVotePlans(nodesVotePlans[0], nvp)...)
+		}
+		for _, divergence := range divergences {
+			fmt.Println(divergence)
+		}
+		if len(divergences) > 0 {
+			fmt.Printf("Nodes consensus failed: %d divergences\n", len(divergences))
+			os.Exit(1)
+		}
+		fmt.Printf("Nodes consensus: %d nodes agree\n", len(nodesVotePlans))
+	}
 	kit.FatalOn(getData(&client, prUrl, &proposals), "getData Proposals")
 	kit.FatalOn(getData(&client, fuUrl, &funds), "getData Funds")
+	if *funding || *format == "md" || *format == "html" {
+		chUrl, err := url.ParseRequestURI(*serviceUrl + *challUrl)
+		kit.FatalOn(err, "url.ParseRequestURI:", *challUrl)
+		err = getData(&client, chUrl, &challenges)
+		// the challenges budget is needed for the funding
+		if *funding {
+			kit.FatalOn(err, "getData Challenges")
+		}
+		// the sections are named after the challenge id without it
+		if err != nil {
+			fmt.Printf("getData Challenges: %s - using the challenge ids\n", err)
+		}
+	}
+
+	// Verify only
+	if *verify {
+		issues := verifyTally(proposals, votePlans)
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if len(issues) > 0 {
+			fmt.Printf("Tally verification failed: %d inconsistencies\n", len(issues))
+			os.Exit(1)
+		}
+		fmt.Printf("Tally verified: %d proposals\n", len(proposals))
+		os.Exit(0)
+	}
 
 	for i := range proposals {
 		for x := range votePlans {
@@ -260,13 +973,37 @@ func main() {
 		}
 	}
 
+	// Funding decisions
+	var summary *FundingSummary
+	if *funding {
+		if *approvalThreshold < 0 || *approvalThreshold > 100 {
+			kit.FatalOn(fmt.Errorf("approval threshold [%v] not a percentage", *approvalThreshold), "approval-threshold")
+		}
+		fs := applyFunding(proposals, challenges, FundingRules{
+			ApprovalThreshold: *approvalThreshold,
+			VotingPower:       *votingPower,
+			FundBudget:        *fundBudget,
+		})
+		summary = &fs
+		fmt.Printf("Funded: %d proposals - %d ADA\n", fs.Funded, fs.Allocated)
+	}
+
 	// TallyResult - dump
 	tallyFile, err := os.Create(*tallyResultFile)
-	kit.FatalOn(err, "tallyFile csv CREATE", *tallyResultFile)
-	err = gocsv.MarshalFile(&proposals, tallyFile)
-	kit.FatalOn(err, "tallyFile csv WRITE", *tallyResultFile)
+	kit.FatalOn(err, "tallyFile", *format, "CREATE", *tallyResultFile)
+	switch *format {
+	case "json":
+		err = marshalResultJSON(proposals, tallyFile)
+	case "md":
+		err = marshalResultMd(proposals, challenges, funds, summary, tallyFile)
+	case "html":
+		err = marshalResultHTML(proposals, challenges, funds, summary, tallyFile)
+	default:
+		err = marshalResultCsv(proposals, tallyFile)
+	}
+	kit.FatalOn(err, "tallyFile", *format, "WRITE", *tallyResultFile)
 	err = tallyFile.Close()
-	kit.FatalOn(err, "tallyFile csv CLOSE", *tallyResultFile)
+	kit.FatalOn(err, "tallyFile", *format, "CLOSE", *tallyResultFile)
 
 	fmt.Printf("Result ready at: %s\n", *tallyResultFile)
 }
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/input-output-hk/jorvit/internal/loader"
+)
+
+// proposalResult returns a tallied (when tally is not nil) proposal of challenge requesting funds ADA.
+func proposalResult(id uint64, challenge uint32, funds uint64, tally map[string]uint) ProposalsResult {
+	p := ProposalsResult{Tally: tally}
+	p.InternalID = id
+	p.ChallengeID = challenge
+	p.Funds = loader.Lovelace(funds * 1_000_000)
+	p.ChainVotePlan = &loader.ChainVotePlan{}
+	return p
+}
+
+func yesNo(yes, no uint) map[string]uint {
+	return map[string]uint{"blank": 0, "yes": yes, "no": no}
+}
+
+func TestApplyFunding(t *testing.T) {
+	type want struct {
+		rank     uint
+		approved bool
+		funded   bool
+		reason   string
+	}
+
+	tests := []struct {
+		name       string
+		proposals  []ProposalsResult
+		challenges []loader.ChallengeData
+		rules      FundingRules
+		want       map[uint64]want // by internal id
+		funded     int
+		allocated  uint64
+		budgetLeft uint64
+	}{
+		{
+			name: "threshold on each proposal votes",
+			proposals: []ProposalsResult{
+				proposalResult(1, 1, 10, yesNo(60, 40)), // net 20 >= 10% of 100
+				proposalResult(2, 1, 10, yesNo(52, 50)), // net 2 < 10% of 102
+			},
+			challenges: []loader.ChallengeData{{ID: 1, RewardsTotal: 100}},
+			rules:      FundingRules{ApprovalThreshold: 10},
+			want: map[uint64]want{
+				1: {rank: 1, approved: true, funded: true, reason: "funded"},
+				2: {rank: 2, reason: "below approval threshold (net yes 2 < 11)"},
+			},
+			funded:    1,
+			allocated: 10,
+		},
+		{
+			name: "threshold on the voting power",
+			proposals: []ProposalsResult{
+				proposalResult(1, 1, 10, yesNo(60, 40)), // net 20 < 10% of 1000
+				proposalResult(2, 1, 10, yesNo(150, 0)), // net 150 >= 10% of 1000
+			},
+			challenges: []loader.ChallengeData{{ID: 1, RewardsTotal: 100}},
+			rules:      FundingRules{ApprovalThreshold: 10, VotingPower: 1000},
+			want: map[uint64]want{
+				1: {rank: 2, reason: "below approval threshold (net yes 20 < 100)"},
+				2: {rank: 1, approved: true, funded: true, reason: "funded"},
+			},
+			funded:    1,
+			allocated: 10,
+		},
+		{
+			name: "net yes has to be positive",
+			proposals: []ProposalsResult{
+				proposalResult(1, 1, 10, yesNo(10, 10)),
+				proposalResult(2, 1, 10, yesNo(0, 0)),
+			},
+			challenges: []loader.ChallengeData{{ID: 1, RewardsTotal: 100}},
+			rules:      FundingRules{},
+			want: map[uint64]want{
+				1: {rank: 1, reason: "below approval threshold (net yes 0 < 1)"},
+				2: {rank: 2, reason: "below approval threshold (net yes 0 < 1)"},
+			},
+		},
+		{
+			name: "ranked by net yes, then yes votes",
+			proposals: []ProposalsResult{
+				proposalResult(1, 1, 10, yesNo(60, 10)),  // net 50, yes 60
+				proposalResult(2, 1, 10, yesNo(100, 50)), // net 50, yes 100
+				proposalResult(3, 1, 10, yesNo(70, 0)),   // net 70
+			},
+			challenges: []loader.ChallengeData{{ID: 1, RewardsTotal: 100}},
+			rules:      FundingRules{},
+			want: map[uint64]want{
+				1: {rank: 3, approved: true, funded: true, reason: "funded"},
+				2: {rank: 2, approved: true, funded: true, reason: "funded"},
+				3: {rank: 1, approved: true, funded: true, reason: "funded"},
+			},
+			funded:    3,
+			allocated: 30,
+		},
+		{
+			name: "greedy, over budget skipped",
+			proposals: []ProposalsResult{
+				proposalResult(1, 1, 60, yesNo(300, 0)),
+				proposalResult(2, 1, 50, yesNo(200, 0)),
+				proposalResult(3, 1, 30, yesNo(100, 0)),
+			},
+			challenges: []loader.ChallengeData{{ID: 1, RewardsTotal: 100}},
+			rules:      FundingRules{},
+			want: map[uint64]want{
+				1: {rank: 1, approved: true, funded: true, reason: "funded"},
+				2: {rank: 2, approved: true, reason: "over challenge budget (40 left)"},
+				3: {rank: 3, approved: true, funded: true, reason: "funded"},
+			},
+			funded:    2,
+			allocated: 90,
+		},
+		{
+			name: "fund budget shared in challenge id order",
+			proposals: []ProposalsResult{
+				proposalResult(1, 2, 50, yesNo(300, 0)),
+				proposalResult(2, 2, 40, yesNo(200, 0)),
+				proposalResult(3, 1, 80, yesNo(100, 0)),
+			},
+			challenges: []loader.ChallengeData{{ID: 2, RewardsTotal: 100}, {ID: 1, RewardsTotal: 100}},
+			rules:      FundingRules{FundBudget: 120},
+			want: map[uint64]want{
+				1: {rank: 1, approved: true, reason: "over fund budget (40 left)"},
+				2: {rank: 2, approved: true, funded: true, reason: "funded"},
+				3: {rank: 1, approved: true, funded: true, reason: "funded"},
+			},
+			funded:     2,
+			allocated:  120,
+			budgetLeft: 0,
+		},
+		{
+			name: "unknown challenge has no budget",
+			proposals: []ProposalsResult{
+				proposalResult(1, 9, 1, yesNo(300, 0)),
+			},
+			challenges: []loader.ChallengeData{{ID: 1, RewardsTotal: 100}},
+			rules:      FundingRules{},
+			want: map[uint64]want{
+				1: {rank: 1, approved: true, reason: "over challenge budget (0 left)"},
+			},
+		},
+		{
+			name: "not tallied",
+			proposals: []ProposalsResult{
+				proposalResult(1, 1, 10, nil),
+				proposalResult(2, 1, 10, yesNo(100, 0)),
+			},
+			challenges: []loader.ChallengeData{{ID: 1, RewardsTotal: 100}},
+			rules:      FundingRules{},
+			want: map[uint64]want{
+				1: {rank: 0, reason: "not tallied yet"},
+				2: {rank: 1, approved: true, funded: true, reason: "funded"},
+			},
+			funded:    1,
+			allocated: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := applyFunding(tt.proposals, tt.challenges, tt.rules)
+
+			for _, p := range tt.proposals {
+				w, f := tt.want[p.InternalID], p.Funding
+				if f.Rank != w.rank || f.Approved != w.approved || f.Funded != w.funded || f.Reason != w.reason {
+					t.Errorf("proposal %d = rank %d, approved %t, funded %t, reason %q; want rank %d, approved %t, funded %t, reason %q",
+						p.InternalID, f.Rank, f.Approved, f.Funded, f.Reason, w.rank, w.approved, w.funded, w.reason)
+				}
+			}
+			if summary.Funded != tt.funded || summary.Allocated != tt.allocated || summary.BudgetLeft != tt.budgetLeft {
+				t.Errorf("summary = funded %d, allocated %d, budget left %d; want %d, %d, %d",
+					summary.Funded, summary.Allocated, summary.BudgetLeft, tt.funded, tt.allocated, tt.budgetLeft)
+			}
+		})
+	}
+}
+
+func TestAda(t *testing.T) {
+	tests := []struct {
+		lovelace loader.Lovelace
+		want     uint64
+	}{
+		{0, 0},
+		{1, 1},
+		{1_000_000, 1},
+		{1_000_001, 2},
+	}
+	for _, tt := range tests {
+		if got := ada(tt.lovelace); got != tt.want {
+			t.Errorf("ada(%d) = %d, want %d", tt.lovelace, got, tt.want)
+		}
+	}
+}
+
+// stationProposal returns the station proposal id of voteplan vpID at index, with options vote options.
+func stationProposal(id string, vpID string, index uint8, chainID string, options ...string) ProposalsResult {
+	p := ProposalsResult{}
+	p.ID = id
+	p.ChainVotePlan = &loader.ChainVotePlan{VotePlanID: vpID}
+	p.ChainProposal = loader.ChainProposal{ExternalID: chainID, Index: index, VoteOptions: loader.ChainVoteOptions{}}
+	for i, o := range options {
+		p.VoteOptions[o] = uint8(i)
+	}
+	return p
+}
+
+// voteProposal returns the voteplan proposal at index with options vote options, public and private tally results.
+func voteProposal(index uint8, chainID string, options uint8, votesCast uint, public []uint, private []uint) VoteProposal {
+	vp := VoteProposal{Index: index, ProposalID: chainID, Options: VoteOption{Start: 0, End: options}, VotesCast: votesCast}
+	vp.Tally.Public.Result.Results = public
+	vp.Tally.Private.State.Decrypted.Result.Results = private
+	return vp
+}
+
+func TestVerifyTally(t *testing.T) {
+	tests := []struct {
+		name      string
+		proposals []ProposalsResult
+		votePlans []VotePlans
+		want      []string
+	}{
+		{
+			name: "consistent",
+			proposals: []ProposalsResult{
+				stationProposal("p0", "vp", 0, "e0", "blank", "yes", "no"),
+				stationProposal("p1", "vp", 1, "e1", "blank", "yes", "no"),
+			},
+			votePlans: []VotePlans{{ID: "vp", Proposals: []VoteProposal{
+				voteProposal(0, "e0", 3, 2, []uint{0, 10, 5}, nil),
+				voteProposal(1, "e1", 3, 0, nil, nil), // not tallied yet
+			}}},
+			want: []string{},
+		},
+		{
+			name: "missing and unknown proposals",
+			proposals: []ProposalsResult{
+				stationProposal("p0", "vp", 0, "e0", "blank", "yes", "no"),
+				stationProposal("p5", "other", 0, "e5", "blank", "yes", "no"),
+			},
+			votePlans: []VotePlans{{ID: "vp", Proposals: []VoteProposal{
+				voteProposal(0, "e0", 3, 0, nil, nil),
+				voteProposal(1, "e1", 3, 0, nil, nil),
+			}}},
+			want: []string{
+				"voteplan [vp] proposal [1] (e1) unknown to the station",
+				"proposal [p5] missing from voteplan [other] at index [0]",
+			},
+		},
+		{
+			name: "chain id and options mismatch",
+			proposals: []ProposalsResult{
+				stationProposal("p0", "vp", 0, "e0", "yes", "no"),
+			},
+			votePlans: []VotePlans{{ID: "vp", Proposals: []VoteProposal{
+				voteProposal(0, "zz", 3, 0, nil, nil),
+			}}},
+			want: []string{
+				"proposal [p0] chain id [e0] does not match voteplan [vp] index [0] id [zz]",
+				"proposal [p0] has [2] vote options, voteplan [vp] index [0] has [3]",
+			},
+		},
+		{
+			name: "tally inconsistencies",
+			proposals: []ProposalsResult{
+				stationProposal("p0", "vp", 0, "e0", "blank", "yes", "no"),
+				stationProposal("p1", "vp", 1, "e1", "blank", "yes", "no"),
+				stationProposal("p2", "vp", 2, "e2", "blank", "yes", "no"),
+				stationProposal("p3", "vp", 3, "e3", "blank", "yes", "no"),
+			},
+			votePlans: []VotePlans{{ID: "vp", Proposals: []VoteProposal{
+				voteProposal(0, "e0", 3, 1, []uint{0, 1, 0}, []uint{0, 1, 0}),
+				voteProposal(1, "e1", 3, 1, []uint{0, 1}, nil),
+				voteProposal(2, "e2", 3, 0, nil, []uint{0, 7, 0}),
+				voteProposal(3, "e3", 3, 4, []uint{0, 0, 0}, nil),
+			}}},
+			want: []string{
+				"voteplan [vp] proposal [0] has both public and private tally",
+				"voteplan [vp] proposal [1] has [2] tally results for [3] options",
+				"voteplan [vp] proposal [2] tally results sum [7] with [0] votes cast",
+				"voteplan [vp] proposal [3] tally results sum [0] with [4] votes cast",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := verifyTally(tt.proposals, tt.votePlans)
+			if !equalStrings(got, tt.want) {
+				t.Errorf("verifyTally() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCompareVotePlans(t *testing.T) {
+	node := func(name string, proposals ...VoteProposal) nodeVotePlans {
+		return nodeVotePlans{Node: name, VotePlans: []VotePlans{{ID: "vp", Proposals: proposals}}}
+	}
+
+	tests := []struct {
+		name string
+		ref  nodeVotePlans
+		node nodeVotePlans
+		want []string
+	}{
+		{
+			name: "agree",
+			ref:  node("n1", voteProposal(0, "e0", 3, 2, []uint{0, 3, 1}, nil), voteProposal(1, "e1", 3, 0, nil, nil)),
+			node: node("n2", voteProposal(0, "e0", 3, 2, []uint{0, 3, 1}, nil), voteProposal(1, "e1", 3, 0, []uint{}, nil)),
+			want: []string{},
+		},
+		{
+			name: "votes cast and tally differ",
+			ref:  node("n1", voteProposal(0, "e0", 3, 2, []uint{0, 3, 1}, nil), voteProposal(1, "e1", 3, 1, nil, []uint{0, 1, 0})),
+			node: node("n2", voteProposal(0, "e0", 3, 1, []uint{0, 3, 1}, nil), voteProposal(1, "e1", 3, 1, nil, []uint{0, 0, 1})),
+			want: []string{
+				"voteplan [vp] proposal [0] votes cast [2] on [n1], [1] on [n2]",
+				"voteplan [vp] proposal [1] tally [0 1 0] on [n1], [0 0 1] on [n2]",
+			},
+		},
+		{
+			name: "tallied on one node only",
+			ref:  node("n1", voteProposal(0, "e0", 3, 2, []uint{0, 2, 0}, nil)),
+			node: node("n2", voteProposal(0, "e0", 3, 2, nil, nil)),
+			want: []string{
+				"voteplan [vp] proposal [0] tally [0 2 0] on [n1], [] on [n2]",
+			},
+		},
+		{
+			name: "missing proposals",
+			ref:  node("n1", voteProposal(0, "e0", 3, 0, nil, nil), voteProposal(1, "e1", 3, 0, nil, nil)),
+			node: node("n2", voteProposal(1, "e1", 3, 0, nil, nil), voteProposal(2, "e2", 3, 0, nil, nil)),
+			want: []string{
+				"voteplan [vp] proposal [0] missing on [n2]",
+				"voteplan [vp] proposal [2] missing on [n1]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareVotePlans(tt.ref, tt.node)
+			if !equalStrings(got, tt.want) {
+				t.Errorf("compareVotePlans() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
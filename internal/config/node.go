@@ -1,3 +1,53 @@
 package config
 
-// TODO:
+// Node contains the jörmungandr node related settings.
+type Node struct {
+	Listen        string  `json:"listen"         yaml:"listen"`         // P2P IP:PORT
+	Rest          string  `json:"rest"           yaml:"rest"`           // REST api IP:PORT
+	Explorer      bool    `json:"explorer"       yaml:"explorer"`       // enable explorer
+	Cors          string  `json:"cors"           yaml:"cors"`           // comma separated list of CORS allowed origins
+	LogLevel      string  `json:"log_level"      yaml:"log_level"`      // off, critical, error, warn, info, debug, trace
+	SkipBootstrap bool    `json:"skip_bootstrap" yaml:"skip_bootstrap"` // first/single genesis leader
+	Start         bool    `json:"start"          yaml:"start"`          // start the node, otherwise only config is generated
+	AllowRestart  bool    `json:"allow_restart"  yaml:"allow_restart"`  // allow manual node restart (once the automatic ones are exhausted)
+	Shutdown      bool    `json:"shutdown"       yaml:"shutdown"`       // try node shutdown on exit if restarted manually
+	Restart       Restart `json:"restart"        yaml:"restart"`        // automatic restart policy
+}
+
+// Restart contains the automatic restart policy of a service that exits unexpectedly.
+type Restart struct {
+	Max     uint   `json:"max"     yaml:"max"`     // max number of restarts, 0 disables
+	Backoff string `json:"backoff" yaml:"backoff"` // delay before the first restart, doubled on each next one
+}
+
+// Station contains the vit-servicing-station-server related settings.
+type Station struct {
+	Listen            string   `json:"listen"              yaml:"listen"`              // IP:PORT
+	LogLevel          string   `json:"log_level"           yaml:"log_level"`           // off, critical, error, warn, info, debug, trace
+	Start             bool     `json:"start"               yaml:"start"`               // start the station, otherwise only config is generated
+	BuiltIn           bool     `json:"builtin"             yaml:"builtin"`             // serve the station api from jorvit, used also when the server binary is missing
+	ApiTokens         []string `json:"api_tokens"          yaml:"api_tokens"`          // API-Token values accepted by the station and the proxy
+	ApiTokensGenerate uint     `json:"api_tokens_generate" yaml:"api_tokens_generate"` // number of API-Token values to generate
+	Restart           Restart  `json:"restart"             yaml:"restart"`             // automatic restart policy (external station)
+}
+
+// Proxy contains the internal REST api proxy related settings.
+type Proxy struct {
+	Listen         string   `json:"listen"           yaml:"listen"`           // IP:PORT
+	Datastore      string   `json:"datastore"        yaml:"datastore"`        // memory or sqlite (vit station DB)
+	ApiTokenExempt []string `json:"api_token_exempt" yaml:"api_token_exempt"` // /api/v0 routes served without API-Token, ex: block0
+}
+
+// Tls contains the proxy and vit station HTTPS settings.
+// HTTPS is enabled when a cert/key pair is provided or SelfSigned is set.
+type Tls struct {
+	CertFile   string   `json:"cert_file"   yaml:"cert_file"`   // PEM certificate (chain)
+	KeyFile    string   `json:"key_file"    yaml:"key_file"`    // PEM private key
+	SelfSigned bool     `json:"self_signed" yaml:"self_signed"` // generate a CA and a server certificate in the working dir
+	Hosts      []string `json:"hosts"       yaml:"hosts"`       // extra DNS names/IPs of the generated server certificate
+}
+
+// Enabled reports whether HTTPS is enabled.
+func (t *Tls) Enabled() bool {
+	return t.SelfSigned || t.CertFile != "" || t.KeyFile != ""
+}
@@ -0,0 +1,227 @@
+// Package config provides the VIT scenario definition,
+// loadable from YAML or JSON files.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Scenario contains all the settings needed to build and run a VIT environment.
+type Scenario struct {
+	Genesis    Genesis   `json:"genesis"     yaml:"genesis"`
+	Vote       Vote      `json:"vote"        yaml:"vote"`
+	Fees       Fees      `json:"fees"        yaml:"fees"`
+	Leaders    Leaders   `json:"bft_leaders" yaml:"bft_leaders"`
+	Committee  Committee `json:"committee"   yaml:"committee"`
+	Voters     Voters    `json:"voters"      yaml:"voters"`
+	Node       Node      `json:"node"        yaml:"node"`
+	Station    Station   `json:"vit_station" yaml:"vit_station"`
+	Proxy      Proxy     `json:"proxy"       yaml:"proxy"`
+	Tls        Tls       `json:"tls"         yaml:"tls"`
+	Assets     Assets    `json:"assets"      yaml:"assets"`
+	TimeFormat string    `json:"time_format" yaml:"time_format"` // display only, go lang format
+
+	StopTimeout  string `json:"stop_timeout"  yaml:"stop_timeout"`  // services graceful stop timeout, ex: 10s
+	ReadyTimeout string `json:"ready_timeout" yaml:"ready_timeout"` // time the started services have to become ready, ex: 60s
+
+	// Reproducible mode - generated keys and working directory are derived from Seed
+	// and genesis time is pinned, so the same inputs produce the same block0 and voteplans.
+	Reproducible bool   `json:"reproducible" yaml:"reproducible"`
+	Seed         string `json:"seed"         yaml:"seed"`  // implies reproducible when set
+	Clean        bool   `json:"clean"        yaml:"clean"` // remove the working directory of a previous run with the same seed
+}
+
+// DefaultSeed used in reproducible mode when no seed is provided.
+const DefaultSeed = "jorvit"
+
+// ReproducibleGenesisTime used in reproducible mode when no genesis time is provided.
+const ReproducibleGenesisTime = "2021-01-01T00:00:00Z"
+
+// IsReproducible reports whether the reproducible mode is enabled.
+func (s *Scenario) IsReproducible() bool {
+	return s.Reproducible || s.Seed != ""
+}
+
+// Genesis contains the block0 timing settings.
+type Genesis struct {
+	Time          string `json:"time"           yaml:"time"`           // RFC3339, empty means Now()
+	SlotDuration  string `json:"slot_duration"  yaml:"slot_duration"`  // 1s-255s
+	EpochDuration string `json:"epoch_duration" yaml:"epoch_duration"` // multiple of slot_duration
+}
+
+// Vote contains the voting and committee timing and voteplan settings.
+type Vote struct {
+	Start                string `json:"start"                  yaml:"start"`                  // RFC3339, empty means genesis time
+	End                  string `json:"end"                    yaml:"end"`                    // RFC3339, empty means start + duration
+	Duration             string `json:"duration"               yaml:"duration"`               // ignored if end is set
+	CommitteeEnd         string `json:"committee_end"          yaml:"committee_end"`          // RFC3339, empty means end + committee_duration
+	CommitteeDuration    string `json:"committee_duration"     yaml:"committee_duration"`     // ignored if committee_end is set
+	VotePlanProposalsMax uint   `json:"voteplan_proposals_max" yaml:"voteplan_proposals_max"` // [1-256]
+	Block0VotePlan       bool   `json:"block0_voteplan"        yaml:"block0_voteplan"`        // include signed voteplans on block0
+	SubmitVotePlan       bool   `json:"submit_voteplan"        yaml:"submit_voteplan"`        // submit the voteplans to the node after genesis
+	AutoTally            bool   `json:"auto_tally"             yaml:"auto_tally"`             // tally the voteplans once the vote ends
+}
+
+// Fees contains the block0 linear fees settings (lovelace).
+type Fees struct {
+	Certificate                 uint64 `json:"certificate"                   yaml:"certificate"`
+	Coefficient                 uint64 `json:"coefficient"                   yaml:"coefficient"`
+	Constant                    uint64 `json:"constant"                      yaml:"constant"`
+	CertificatePoolRegistration uint64 `json:"certificate_pool_registration" yaml:"certificate_pool_registration"`
+	CertificateStakeDelegation  uint64 `json:"certificate_stake_delegation"  yaml:"certificate_stake_delegation"`
+	CertificateVotePlan         uint64 `json:"certificate_vote_plan"         yaml:"certificate_vote_plan"`
+	CertificateVoteCast         uint64 `json:"certificate_vote_cast"         yaml:"certificate_vote_cast"`
+	GoTo                        string `json:"go_to"                         yaml:"go_to"` // rewards or treasury
+}
+
+// Leaders contains the BFT leaders settings.
+type Leaders struct {
+	Min        uint     `json:"min"         yaml:"min"`         // new key pairs are generated if > len(secret_keys) + len(public_keys)
+	SecretKeys []string `json:"secret_keys" yaml:"secret_keys"` // files containing the SK
+	PublicKeys []string `json:"public_keys" yaml:"public_keys"` // PK, no node config will be generated for these
+	Fund       uint64   `json:"fund"        yaml:"fund"`        // lovelace amount to fund each leader account
+}
+
+// Committee contains the global (auth) and voteplan (privacy) committee settings.
+type Committee struct {
+	AuthPublicKeys    []string `json:"auth_public_keys"    yaml:"auth_public_keys"`
+	AuthFund          uint64   `json:"auth_fund"           yaml:"auth_fund"` // lovelace amount to fund each auth member account
+	PrivacyPublicKeys []string `json:"privacy_public_keys" yaml:"privacy_public_keys"`
+}
+
+// Voters contains the generated voter wallets settings, funded on block0.
+type Voters struct {
+	Count        uint    `json:"count"        yaml:"count"`        // number of voter key pairs to generate
+	Distribution string  `json:"distribution" yaml:"distribution"` // fixed, uniform or pareto
+	Value        uint64  `json:"value"        yaml:"value"`        // lovelace, fixed value or uniform/pareto minimum
+	ValueMax     uint64  `json:"value_max"    yaml:"value_max"`    // lovelace, uniform maximum or pareto cap (0 means no cap)
+	ParetoAlpha  float64 `json:"pareto_alpha" yaml:"pareto_alpha"` // pareto shape, the lower the more unequal
+	QrPin        string  `json:"qr_pin"       yaml:"qr_pin"`       // 4 digits PIN encrypting the QR code payloads
+}
+
+// Assets contains the external data files paths.
+type Assets struct {
+	Proposals        string `json:"proposals"          yaml:"proposals"`
+	Fund             string `json:"fund"               yaml:"fund"`
+	Challenges       string `json:"challenges"         yaml:"challenges"`
+	GenesisExtraData string `json:"genesis_extra_data" yaml:"genesis_extra_data"`
+	Watch            bool   `json:"watch"              yaml:"watch"` // reload proposals and fund off chain data on change
+}
+
+// Default returns a Scenario with the same defaults of the commandline flags.
+func Default() *Scenario {
+	assets := "." + string(os.PathSeparator) + "assets" + string(os.PathSeparator)
+
+	return &Scenario{
+		Genesis: Genesis{
+			SlotDuration:  "20s",
+			EpochDuration: "24h",
+		},
+		Vote: Vote{
+			Duration:             "144h",
+			CommitteeDuration:    "24h",
+			VotePlanProposalsMax: 255,
+		},
+		Fees: Fees{
+			GoTo: "rewards",
+		},
+		Leaders: Leaders{
+			Min: 1,
+		},
+		Voters: Voters{
+			Distribution: "fixed",
+			Value:        10000000000,
+			ParetoAlpha:  1.16,
+			QrPin:        "1234",
+		},
+		Node: Node{
+			Listen:        "127.0.0.1:9001",
+			Rest:          "0.0.0.0:8001",
+			Cors:          "http://127.0.0.1,http://localhost",
+			LogLevel:      "warn",
+			SkipBootstrap: true,
+			AllowRestart:  true,
+			Shutdown:      true,
+			Restart:       Restart{Backoff: "1s"},
+		},
+		Station: Station{
+			Listen:   "0.0.0.0:3030",
+			LogLevel: "warn",
+			Restart:  Restart{Backoff: "1s"},
+		},
+		Proxy: Proxy{
+			Listen:    "0.0.0.0:8000",
+			Datastore: "memory",
+		},
+		Assets: Assets{
+			Proposals:        assets + "proposals.csv",
+			Fund:             assets + "fund.csv",
+			Challenges:       assets + "challenges.csv",
+			GenesisExtraData: assets + "extra_genesis_data.yaml",
+		},
+		TimeFormat:   time.RFC3339,
+		StopTimeout:  "10s",
+		ReadyTimeout: "60s",
+	}
+}
+
+// Load returns the Default Scenario updated with the values found in filename.
+func Load(filename string) (*Scenario, error) {
+	scenario := Default()
+	if err := scenario.LoadFile(filename); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// LoadFile updates the scenario with the values found in filename.
+// Files with ".json" extension are decoded as JSON, everything else as YAML.
+// Settings not present in the file keep their current value.
+func (s *Scenario) LoadFile(filename string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(s)
+	}
+	return yaml.UnmarshalStrict(data, s)
+}
+
+// ToYaml returns the YAML representation of the scenario.
+func (s *Scenario) ToYaml() ([]byte, error) {
+	return yaml.Marshal(s)
+}
+
+// FileFromArgs returns the value of the commandline flag "name" (-name value, -name=value)
+// without parsing the whole set, so the file can be loaded before the other flags.
+func FileFromArgs(args []string, name string) string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		switch {
+		case arg == name && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, name+"="):
+			return strings.TrimPrefix(arg, name+"=")
+		}
+	}
+	return ""
+}
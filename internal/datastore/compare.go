@@ -0,0 +1,54 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// CompareProposals returns the differences (by internal id) between the proposals
+// served from the two stores, as they would be returned by the API.
+func CompareProposals(a, b ProposalsStore) []string {
+	diff := make([]string, 0)
+	if a.Total() != b.Total() {
+		diff = append(diff, fmt.Sprintf("proposals total: %d != %d", a.Total(), b.Total()))
+	}
+	for _, pa := range *a.All() {
+		pb := b.SearchID(strconv.FormatUint(pa.InternalID, 10))
+		if pb == nil {
+			diff = append(diff, fmt.Sprintf("proposal [%d]: missing", pa.InternalID))
+			continue
+		}
+		if !sameJSON(pa, pb) {
+			diff = append(diff, fmt.Sprintf("proposal [%d]: different", pa.InternalID))
+		}
+	}
+	return diff
+}
+
+// CompareFunds returns the differences (by id) between the funds
+// served from the two stores, as they would be returned by the API.
+func CompareFunds(a, b FundsStore) []string {
+	diff := make([]string, 0)
+	if a.Total() != b.Total() {
+		diff = append(diff, fmt.Sprintf("funds total: %d != %d", a.Total(), b.Total()))
+	}
+	for _, fa := range *a.All() {
+		fb := b.SearchID(strconv.FormatUint(fa.FundID, 10))
+		if fb == nil {
+			diff = append(diff, fmt.Sprintf("fund [%d]: missing", fa.FundID))
+			continue
+		}
+		if !sameJSON(fa, fb) {
+			diff = append(diff, fmt.Sprintf("fund [%d]: different", fa.FundID))
+		}
+	}
+	return diff
+}
+
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
@@ -6,11 +6,29 @@ type ProposalsStore interface {
 	Initialize(filename string) error
 	All() *[]*loader.ProposalData
 	SearchID(internalID string) *loader.ProposalData
+	SearchExternalID(externalID string) *loader.ProposalData
+	SearchVotePlanID(votePlanID string) *[]*loader.ProposalData
+	SearchChallengeID(challengeID uint32) *[]*loader.ProposalData
+	Reindex() error
+	Reload(filename string) error
+	Add(p *loader.ProposalData) error
+	Update(p *loader.ProposalData) error
+	Delete(internalID string) error
 	Total() int
 }
 
 type FundsStore interface {
 	Initialize(filename string) error
+	All() *[]*loader.FundData
 	First() *loader.FundData
+	SearchID(fundID string) *loader.FundData
+	Reload(filename string) error
+	Total() int
+}
+
+type ChallengesStore interface {
+	Initialize(filename string) error
+	All() *[]*loader.ChallengeData
+	SearchID(challengeID string) *loader.ChallengeData
 	Total() int
 }
@@ -4,58 +4,304 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
 
 	"github.com/input-output-hk/jorvit/internal/loader"
 )
 
+// Proposals is the in memory proposals store, safe for concurrent use.
+// Updates never modify the published list and proposals, but replace them (copy on write),
+// so the data returned by the read methods can be used without further locking.
 type Proposals struct {
-	List *[]*loader.ProposalData `json:"proposals"`
+	List *[]*loader.ProposalData `json:"proposals"` // read only, use All()
+
+	mu sync.RWMutex
+	proposalsIndex
+}
+
+type proposalsIndex struct {
+	byInternalID  map[uint64]*loader.ProposalData
+	byExternalID  map[string]*loader.ProposalData
+	byVotePlanID  map[string][]*loader.ProposalData
+	byChallengeID map[uint32][]*loader.ProposalData
 }
 
 func (b *Proposals) Initialize(filename string) error {
-	file, err := os.Open(filename)
+	list, err := loadProposalsFile(filename)
 	if err != nil {
 		return err
 	}
+	idx, err := indexProposals(list)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.List, b.proposalsIndex = list, idx
+	return nil
+}
+
+func loadProposalsFile(filename string) (*[]*loader.ProposalData, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
-	b.List, err = loader.LoadData(file)
+	list, err := loader.LoadData(file)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range *list {
+		if err = normalizeProposal(v); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// normalizeProposal sets the default values of the optional proposal fields and validates them.
+func normalizeProposal(v *loader.ProposalData) error {
+	// fund_id column is optional, 0 means the first fund
+	if v.ChainVotePlan == nil {
+		v.ChainVotePlan = &loader.ChainVotePlan{}
+	}
+
+	if v.VoteAction == "" {
+		v.VoteAction = "off_chain"
+	}
+	// remove if other versions needed
+	if v.VoteAction != "off_chain" {
+		return fmt.Errorf("%s - expected to be one of (%s) - but [%s] provided", "chain_vote_action", "off_chain", v.VoteAction)
+	}
+
+	if v.VoteType == "" {
+		v.VoteType = "public"
+	} else if v.VoteType != "public" && v.VoteType != "private" {
+		return fmt.Errorf("%s - expected to be one of (%s, %s) - but [%s] provided", "chain_vote_type", "public", "private", v.VoteType)
+	}
+	return nil
+}
+
+func indexProposals(list *[]*loader.ProposalData) (proposalsIndex, error) {
+	idx := proposalsIndex{
+		byInternalID:  make(map[uint64]*loader.ProposalData, len(*list)),
+		byExternalID:  make(map[string]*loader.ProposalData, len(*list)),
+		byVotePlanID:  make(map[string][]*loader.ProposalData),
+		byChallengeID: make(map[uint32][]*loader.ProposalData),
+	}
+
+	for _, v := range *list {
+		if _, ok := idx.byInternalID[v.InternalID]; ok {
+			return idx, fmt.Errorf("%s - duplicate proposal id [%d]", "internal_id", v.InternalID)
+		}
+		idx.byInternalID[v.InternalID] = v
+
+		if v.ExternalID != "" {
+			if _, ok := idx.byExternalID[v.ExternalID]; ok {
+				return idx, fmt.Errorf("%s - duplicate proposal chain id [%s]", "chain_proposal_id", v.ExternalID)
+			}
+			idx.byExternalID[v.ExternalID] = v
+		}
+		if v.ChainVotePlan != nil && v.ChainVotePlan.VotePlanID != "" {
+			idx.byVotePlanID[v.ChainVotePlan.VotePlanID] = append(idx.byVotePlanID[v.ChainVotePlan.VotePlanID], v)
+		}
+		idx.byChallengeID[v.ChallengeID] = append(idx.byChallengeID[v.ChallengeID], v)
+	}
+	return idx, nil
+}
+
+// Reindex rebuilds the lookup indexes,
+// needed after the chain data (external id, voteplan) of the proposals is updated.
+func (b *Proposals) Reindex() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	idx, err := indexProposals(b.List)
 	if err != nil {
 		return err
 	}
+	b.proposalsIndex = idx
+	return nil
+}
 
-	for _, v := range *b.List {
-		if v.VoteAction == "" {
-			v.VoteAction = "off_chain"
+// Reload updates the off chain data (category, texts, urls, proposer) of the proposals
+// with the values found in filename, keeping the chain data.
+// Adding/removing proposals or changing their chain related values is not allowed
+// since it would need a new block0.
+func (b *Proposals) Reload(filename string) error {
+	list, err := loadProposalsFile(filename)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(*list) != len(*b.List) {
+		return fmt.Errorf("proposals added or removed (%d -> %d), block0 needs to be regenerated", len(*b.List), len(*list))
+	}
+
+	reloaded := make(map[uint64]*loader.ProposalData, len(*list))
+	for _, v := range *list {
+		reloaded[v.InternalID] = v
+	}
+
+	newList := make([]*loader.ProposalData, 0, len(*b.List))
+	for _, old := range *b.List {
+		v, ok := reloaded[old.InternalID]
+		switch {
+		case !ok:
+			return fmt.Errorf("proposal [%d] removed, block0 needs to be regenerated", old.InternalID)
+		case v.Proposal.ID != old.Proposal.ID:
+			return fmt.Errorf("proposal [%d] - %s changed, block0 needs to be regenerated", old.InternalID, "proposal_id")
+		case v.VoteType != old.VoteType:
+			return fmt.Errorf("proposal [%d] - %s changed, block0 needs to be regenerated", old.InternalID, "chain_vote_type")
+		case !sameVoteOptions(v.VoteOptions, old.VoteOptions):
+			return fmt.Errorf("proposal [%d] - %s changed, block0 needs to be regenerated", old.InternalID, "chain_vote_options")
+		case v.ChallengeID != old.ChallengeID:
+			return fmt.Errorf("proposal [%d] - %s changed, block0 needs to be regenerated", old.InternalID, "challenge_id")
+		case v.ChainVotePlan.FundID != 0 && v.ChainVotePlan.FundID != old.ChainVotePlan.FundID:
+			return fmt.Errorf("proposal [%d] - %s changed, block0 needs to be regenerated", old.InternalID, "fund_id")
 		}
-		// remove if other versions needed
-		if v.VoteAction != "off_chain" {
-			return fmt.Errorf("%s - expected to be one of (%s) - but [%s] provided", "chain_vote_action", "off_chain", v.VoteAction)
+
+		updated := *old
+		updated.ProposalCategory = v.ProposalCategory
+		updated.Proposal = v.Proposal
+		updated.Proposer = v.Proposer
+		newList = append(newList, &updated)
+	}
+
+	return b.publish(&newList)
+}
+
+func sameVoteOptions(a, b loader.ChainVoteOptions) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for opt, i := range a {
+		if j, ok := b[opt]; !ok || i != j {
+			return false
+		}
+	}
+	return true
+}
+
+// Add appends a new proposal to the store.
+func (b *Proposals) Add(p *loader.ProposalData) error {
+	if err := normalizeProposal(p); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.byInternalID[p.InternalID]; ok {
+		return fmt.Errorf("%s - duplicate proposal id [%d]", "internal_id", p.InternalID)
+	}
+	newList := make([]*loader.ProposalData, 0, len(*b.List)+1)
+	newList = append(newList, *b.List...)
+	newList = append(newList, p)
+
+	return b.publish(&newList)
+}
+
+// Update replaces the proposal with the same internal id.
+func (b *Proposals) Update(p *loader.ProposalData) error {
+	if err := normalizeProposal(p); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.byInternalID[p.InternalID]; !ok {
+		return fmt.Errorf("%s - proposal [%d] not found", "internal_id", p.InternalID)
+	}
+	newList := make([]*loader.ProposalData, len(*b.List))
+	for i, v := range *b.List {
+		newList[i] = v
+		if v.InternalID == p.InternalID {
+			newList[i] = p
 		}
+	}
+
+	return b.publish(&newList)
+}
+
+// Delete removes the proposal with internalID.
+func (b *Proposals) Delete(internalID string) error {
+	id, err := strconv.ParseUint(internalID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s - %w", "internal_id", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-		if v.VoteType == "" {
-			v.VoteType = "public"
-		} else if v.VoteType != "public" && v.VoteType != "private" {
-			return fmt.Errorf("%s - expected to be one of (%s, %s) - but [%s] provided", "chain_vote_type", "public", "private", v.VoteType)
+	if _, ok := b.byInternalID[id]; !ok {
+		return fmt.Errorf("%s - proposal [%d] not found", "internal_id", id)
+	}
+	newList := make([]*loader.ProposalData, 0, len(*b.List)-1)
+	for _, v := range *b.List {
+		if v.InternalID != id {
+			newList = append(newList, v)
 		}
 	}
+
+	return b.publish(&newList)
+}
+
+// publish indexes and replaces the current list, lock must be held.
+func (b *Proposals) publish(list *[]*loader.ProposalData) error {
+	idx, err := indexProposals(list)
+	if err != nil {
+		return err
+	}
+	b.List, b.proposalsIndex = list, idx
 	return nil
 }
 
 func (b *Proposals) All() *[]*loader.ProposalData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return b.List
 }
 
 func (b *Proposals) SearchID(internalID string) *loader.ProposalData {
-	ret := FilterSingle(
-		b.List,
-		func(v *loader.ProposalData) bool {
-			return strconv.FormatUint(v.InternalID, 10) == internalID
-		},
-	)
-	return ret
+	id, err := strconv.ParseUint(internalID, 10, 64)
+	if err != nil {
+		return nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.byInternalID[id]
+}
+
+func (b *Proposals) SearchExternalID(externalID string) *loader.ProposalData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.byExternalID[externalID]
+}
+
+func (b *Proposals) SearchVotePlanID(votePlanID string) *[]*loader.ProposalData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ret := append([]*loader.ProposalData{}, b.byVotePlanID[votePlanID]...)
+	return &ret
+}
+
+func (b *Proposals) SearchChallengeID(challengeID uint32) *[]*loader.ProposalData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	ret := append([]*loader.ProposalData{}, b.byChallengeID[challengeID]...)
+	return &ret
 }
 
 func (b *Proposals) Total() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return len(*b.List)
 }
 
@@ -78,33 +324,180 @@ func FilterSingle(vs *[]*loader.ProposalData, f func(*loader.ProposalData) bool)
 	return nil
 }
 
+// Funds is the in memory funds store, safe for concurrent use (copy on write updates).
 type Funds struct {
-	List *[]*loader.FundData `json:"funds"`
+	List *[]*loader.FundData `json:"funds"` // read only, use All()
+
+	mu   sync.RWMutex
+	byID map[uint64]*loader.FundData
 }
 
 func (b *Funds) Initialize(filename string) error {
-	file, err := os.Open(filename)
+	list, byID, err := loadFundsFile(filename)
 	if err != nil {
 		return err
 	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.List, b.byID = list, byID
+	return nil
+}
+
+func loadFundsFile(filename string) (*[]*loader.FundData, map[uint64]*loader.FundData, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer file.Close()
 
-	b.List, err = loader.LoadFundData(file)
+	list, err := loader.LoadFundData(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[uint64]*loader.FundData, len(*list))
+	for _, v := range *list {
+		if v.FundID == 0 {
+			return nil, nil, fmt.Errorf("%s - fund [%s] has no id", "id", v.Name)
+		}
+		if _, ok := byID[v.FundID]; ok {
+			return nil, nil, fmt.Errorf("%s - duplicate fund id [%d]", "id", v.FundID)
+		}
+		byID[v.FundID] = v
+	}
+
+	return list, byID, nil
+}
+
+// Reload updates the off chain data (name, goal, info and display times) of the funds
+// with the values found in filename, keeping the voteplans.
+// Empty values keep the current ones. Adding/removing funds or changing
+// their voteplans timing is not allowed since it would need a new block0.
+func (b *Funds) Reload(filename string) error {
+	list, byID, err := loadFundsFile(filename)
 	if err != nil {
 		return err
 	}
 
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(*list) != len(*b.List) {
+		return fmt.Errorf("funds added or removed (%d -> %d), block0 needs to be regenerated", len(*b.List), len(*list))
+	}
+
+	newList := make([]*loader.FundData, 0, len(*b.List))
+	newByID := make(map[uint64]*loader.FundData, len(*b.List))
+	for _, old := range *b.List {
+		v, ok := byID[old.FundID]
+		switch {
+		case !ok:
+			return fmt.Errorf("fund [%d] removed, block0 needs to be regenerated", old.FundID)
+		case v.VoteStart != "" && v.VoteStart != old.VoteStart,
+			v.VoteEnd != "" && v.VoteEnd != old.VoteEnd,
+			v.CommitteeEnd != "" && v.CommitteeEnd != old.CommitteeEnd:
+			return fmt.Errorf("fund [%d] - voteplans timing changed, block0 needs to be regenerated", old.FundID)
+		}
+
+		updated := *old
+		updated.Name = v.Name
+		updated.Goal = v.Goal
+		updated.VotingPowerThreshold = v.VotingPowerThreshold
+		setIfNotEmpty(&updated.VotingPowerInfo, v.VotingPowerInfo)
+		setIfNotEmpty(&updated.RewardsInfo, v.RewardsInfo)
+		setIfNotEmpty(&updated.StartTime, v.StartTime)
+		setIfNotEmpty(&updated.EndTime, v.EndTime)
+		setIfNotEmpty(&updated.NextStartTime, v.NextStartTime)
+		newList = append(newList, &updated)
+		newByID[updated.FundID] = &updated
+	}
+
+	b.List, b.byID = &newList, newByID
 	return nil
 }
 
-// tmp
+func setIfNotEmpty(dst *string, src string) {
+	if src != "" {
+		*dst = src
+	}
+}
+
+func (b *Funds) All() *[]*loader.FundData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.List
+}
+
 func (b *Funds) First() *loader.FundData {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	if len(*b.List) == 0 {
 		return nil
 	}
 	return (*b.List)[0]
 }
 
+func (b *Funds) SearchID(fundID string) *loader.FundData {
+	id, err := strconv.ParseUint(fundID, 10, 64)
+	if err != nil {
+		return nil
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.byID[id]
+}
+
 func (b *Funds) Total() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(*b.List)
+}
+
+type Challenges struct {
+	List *[]*loader.ChallengeData `json:"challenges"`
+
+	byID map[uint32]*loader.ChallengeData
+}
+
+func (b *Challenges) Initialize(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	b.List, err = loader.LoadChallengeData(file)
+	if err != nil {
+		return err
+	}
+
+	b.byID = make(map[uint32]*loader.ChallengeData, len(*b.List))
+	for _, v := range *b.List {
+		if v.ID == 0 {
+			return fmt.Errorf("%s - challenge [%s] has no id", "id", v.Title)
+		}
+		if _, ok := b.byID[v.ID]; ok {
+			return fmt.Errorf("%s - duplicate challenge id [%d]", "id", v.ID)
+		}
+		b.byID[v.ID] = v
+	}
+
+	return nil
+}
+
+func (b *Challenges) All() *[]*loader.ChallengeData {
+	return b.List
+}
+
+func (b *Challenges) SearchID(challengeID string) *loader.ChallengeData {
+	id, err := strconv.ParseUint(challengeID, 10, 32)
+	if err != nil {
+		return nil
+	}
+	return b.byID[uint32(id)]
+}
+
+func (b *Challenges) Total() int {
 	return len(*b.List)
 }
@@ -0,0 +1,333 @@
+package datastore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/input-output-hk/jorvit/internal/loader"
+
+	_ "modernc.org/sqlite" // pure go sqlite driver
+)
+
+// ErrReadOnly is returned by the stores that don't support updates.
+var ErrReadOnly = errors.New("read only datastore")
+
+// SqliteProposals is a ProposalsStore that reads the proposals (and related voteplans)
+// from the vit-servicing-station SQLite DB, so the served data is the same of the station.
+// The data is read on Initialize/Reload (DB filename) and served from memory.
+type SqliteProposals struct {
+	Proposals
+
+	TimeFormat string // voteplans times format, empty means time.RFC3339
+}
+
+func (b *SqliteProposals) Initialize(dbFile string) error {
+	db, err := openSqlite(dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	votePlans, err := sqliteVotePlans(db, b.TimeFormat)
+	if err != nil {
+		return err
+	}
+	rows, err := sqliteRows(db, "SELECT * FROM proposals ORDER BY id")
+	if err != nil {
+		return err
+	}
+
+	list := make([]*loader.ProposalData, 0, len(rows))
+	for _, r := range rows {
+		p := &loader.ProposalData{
+			InternalID: r.uint("id"),
+			ProposalCategory: loader.ProposalCategory{
+				CategoryName: r.str("proposal_category"),
+			},
+			Proposal: loader.Proposal{
+				ID:          r.str("proposal_id"),
+				Title:       r.str("proposal_title"),
+				Summary:     r.str("proposal_summary"),
+				Problem:     r.str("proposal_problem"),
+				Solution:    r.str("proposal_solution"),
+				ProposalURL: r.str("proposal_url"),
+				DataURL:     r.str("proposal_files_url"),
+				PublicKey:   r.str("proposal_public_key"),
+				Funds:       loader.Lovelace(r.uint("proposal_funds")),
+				ImpactScore: loader.Score(r.uint("proposal_impact_score")),
+			},
+			Proposer: loader.Proposer{
+				ProposerEmail:      r.str("proposer_contact", "proposer_email"),
+				ProposerName:       r.str("proposer_name"),
+				ProposerURL:        r.str("proposer_url"),
+				ProposerExperience: r.str("proposer_relevant_experience"),
+			},
+			ChainProposal: loader.ChainProposal{
+				ExternalID: r.str("chain_proposal_id"),
+				Index:      uint8(r.uint("chain_proposal_index")),
+			},
+			ChallengeID: uint32(r.uint("challenge_id")),
+		}
+		if err = p.VoteOptions.UnmarshalCSV(r.str("chain_vote_options")); err != nil {
+			return fmt.Errorf("proposal [%d] - %s: %w", p.InternalID, "chain_vote_options", err)
+		}
+
+		vp, ok := votePlans[r.str("chain_voteplan_id")]
+		if !ok {
+			return fmt.Errorf("proposal [%d] - %s [%s] not found", p.InternalID, "chain_voteplan_id", r.str("chain_voteplan_id"))
+		}
+		p.ChainVotePlan = vp
+		p.VoteType = vp.Payload
+
+		if err = normalizeProposal(p); err != nil {
+			return err
+		}
+		list = append(list, p)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.publish(&list)
+}
+
+// Reload reads again the data from the DB.
+func (b *SqliteProposals) Reload(dbFile string) error {
+	return b.Initialize(dbFile)
+}
+
+func (b *SqliteProposals) Add(p *loader.ProposalData) error { return ErrReadOnly }
+
+func (b *SqliteProposals) Update(p *loader.ProposalData) error { return ErrReadOnly }
+
+func (b *SqliteProposals) Delete(internalID string) error { return ErrReadOnly }
+
+// SqliteFunds is a FundsStore that reads the funds (and related voteplans)
+// from the vit-servicing-station SQLite DB.
+// The data is read on Initialize/Reload (DB filename) and served from memory.
+type SqliteFunds struct {
+	Funds
+
+	TimeFormat string // voteplans times format, empty means time.RFC3339
+}
+
+func (b *SqliteFunds) Initialize(dbFile string) error {
+	db, err := openSqlite(dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	votePlans, err := sqliteVotePlans(db, b.TimeFormat)
+	if err != nil {
+		return err
+	}
+	rows, err := sqliteRows(db, "SELECT * FROM funds ORDER BY id")
+	if err != nil {
+		return err
+	}
+
+	list := make([]*loader.FundData, 0, len(rows))
+	byID := make(map[uint64]*loader.FundData, len(rows))
+	for _, r := range rows {
+		f := &loader.FundData{
+			FundID:               r.uint("id"),
+			Name:                 r.str("fund_name"),
+			Goal:                 r.str("fund_goal"),
+			VotingPowerThreshold: loader.Lovelace(r.uint("voting_power_threshold")),
+			VotingPowerInfo:      r.time("voting_power_info", b.TimeFormat),
+			RewardsInfo:          r.time("rewards_info", b.TimeFormat),
+			StartTime:            r.time("fund_start_time", b.TimeFormat),
+			EndTime:              r.time("fund_end_time", b.TimeFormat),
+			NextStartTime:        r.time("next_fund_start_time", b.TimeFormat),
+			VotePlans:            make([]loader.ChainVotePlan, 0),
+		}
+		list = append(list, f)
+		byID[f.FundID] = f
+	}
+
+	// keep the voteplans DB order
+	vpRows, err := sqliteRows(db, "SELECT chain_voteplan_id FROM voteplans ORDER BY id")
+	if err != nil {
+		return err
+	}
+	for _, r := range vpRows {
+		vp := votePlans[r.str("chain_voteplan_id")]
+		f, ok := byID[vp.FundID]
+		if !ok {
+			return fmt.Errorf("voteplan [%s] - %s [%d] not found", vp.VotePlanID, "fund_id", vp.FundID)
+		}
+		f.VotePlans = append(f.VotePlans, *vp)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.List, b.byID = &list, byID
+	return nil
+}
+
+// Reload reads again the data from the DB.
+func (b *SqliteFunds) Reload(dbFile string) error {
+	return b.Initialize(dbFile)
+}
+
+// SqliteChallenges is a ChallengesStore that reads the challenges
+// from the vit-servicing-station SQLite DB.
+type SqliteChallenges struct {
+	Challenges
+}
+
+func (b *SqliteChallenges) Initialize(dbFile string) error {
+	db, err := openSqlite(dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := sqliteRows(db, "SELECT * FROM challenges ORDER BY id")
+	if err != nil {
+		return err
+	}
+
+	list := make([]*loader.ChallengeData, 0, len(rows))
+	b.byID = make(map[uint32]*loader.ChallengeData, len(rows))
+	for _, r := range rows {
+		c := &loader.ChallengeData{
+			ID:           uint32(r.uint("id")),
+			Title:        r.str("title"),
+			Description:  r.str("description"),
+			RewardsTotal: r.uint("rewards_total"),
+			FundID:       r.uint("fund_id"),
+			ChallengeURL: r.str("challenge_url"),
+		}
+		list = append(list, c)
+		b.byID[c.ID] = c
+	}
+	b.List = &list
+
+	return nil
+}
+
+func openSqlite(dbFile string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+dbFile+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// sqliteVotePlans returns the DB voteplans by chain voteplan id.
+func sqliteVotePlans(db *sql.DB, timeFormat string) (map[string]*loader.ChainVotePlan, error) {
+	rows, err := sqliteRows(db, "SELECT * FROM voteplans ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	votePlans := make(map[string]*loader.ChainVotePlan, len(rows))
+	for _, r := range rows {
+		vp := &loader.ChainVotePlan{
+			VpInternalID:      r.str("id"),
+			VotePlanID:        r.str("chain_voteplan_id"),
+			VoteStart:         r.time("chain_vote_start_time", timeFormat),
+			VoteEnd:           r.time("chain_vote_end_time", timeFormat),
+			CommitteeEnd:      r.time("chain_committee_end_time", timeFormat),
+			Payload:           r.str("chain_voteplan_payload"),
+			VoteEncryptionKey: r.str("chain_vote_encryption_key"),
+			FundID:            r.uint("fund_id"),
+		}
+		votePlans[vp.VotePlanID] = vp
+	}
+	return votePlans, nil
+}
+
+// sqliteRow is a DB row by column name. Column types are not relied upon,
+// since they changed between the vit-servicing-station versions.
+type sqliteRow map[string]interface{}
+
+func sqliteRows(db *sql.DB, query string) ([]sqliteRow, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]sqliteRow, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(sqliteRow, len(cols))
+		for i, col := range cols {
+			row[col] = values[i]
+		}
+		ret = append(ret, row)
+	}
+	return ret, rows.Err()
+}
+
+// str returns the value of the first available column as string.
+func (r sqliteRow) str(cols ...string) string {
+	for _, col := range cols {
+		switch v := r[col].(type) {
+		case nil:
+			continue
+		case string:
+			return v
+		case []byte:
+			return string(v)
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func (r sqliteRow) uint(col string) uint64 {
+	switch v := r[col].(type) {
+	case int64:
+		return uint64(v)
+	case float64:
+		return uint64(v)
+	default:
+		n, _ := strconv.ParseUint(r.str(col), 10, 64)
+		return n
+	}
+}
+
+// time returns the column value formatted with format,
+// the value can be a unix timestamp or an already formatted string.
+func (r sqliteRow) time(col string, format string) string {
+	if format == "" {
+		format = time.RFC3339
+	}
+	switch v := r[col].(type) {
+	case int64:
+		return time.Unix(v, 0).UTC().Format(format)
+	default:
+		s := r.str(col)
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t.Format(format)
+		}
+		return s
+	}
+}
@@ -120,6 +120,9 @@ type FundData struct {
 	EndTime              string          `json:"fund_end_time"          csv:"fund_end_time"`
 	NextStartTime        string          `json:"next_fund_start_time"   csv:"next_fund_start_time"`
 	VotePlans            []ChainVotePlan `json:"chain_vote_plans"       csv:"-"`
+	VoteStart            string          `json:"-"                      csv:"vote_start_time"`    // RFC3339, voteplans timing
+	VoteEnd              string          `json:"-"                      csv:"vote_end_time"`      // RFC3339, voteplans timing
+	CommitteeEnd         string          `json:"-"                      csv:"committee_end_time"` // RFC3339, voteplans timing
 }
 
 func LoadFundData(r io.Reader) (*[]*FundData, error) {
@@ -127,3 +130,18 @@ func LoadFundData(r io.Reader) (*[]*FundData, error) {
 	err := gocsv.Unmarshal(r, &funds)
 	return &funds, err
 }
+
+type ChallengeData struct {
+	ID           uint32 `json:"id"            csv:"id"`
+	Title        string `json:"title"         csv:"title"`
+	Description  string `json:"description"   csv:"description"`
+	RewardsTotal uint64 `json:"rewards_total" csv:"rewards_total"`
+	FundID       uint64 `json:"fund_id"       csv:"fund_id"`
+	ChallengeURL string `json:"challenge_url" csv:"challenge_url"`
+}
+
+func LoadChallengeData(r io.Reader) (*[]*ChallengeData, error) {
+	challenges := make([]*ChallengeData, 0)
+	err := gocsv.Unmarshal(r, &challenges)
+	return &challenges, err
+}
@@ -0,0 +1,133 @@
+package supervisor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// StderrTailLines is the number of stderr lines reported with the process exit error.
+var StderrTailLines = 10
+
+// Process is a supervised child process.
+// On stop it gets SIGTERM (killed where not supported) and it is killed when forced.
+// The exit error contains the tail of the process stderr.
+type Process struct {
+	NewCmd func() *exec.Cmd // returns the command to run, called on each (re)start
+
+	mu   sync.Mutex
+	cmd  *exec.Cmd
+	done chan struct{}
+	tail *tailWriter
+}
+
+// NewProcess returns the Process running the newCmd commands,
+// each one within its own process group so terminal signals are handled by the supervisor only.
+func NewProcess(newCmd func() *exec.Cmd) *Process {
+	return &Process{NewCmd: newCmd}
+}
+
+func (p *Process) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cmd := p.NewCmd()
+	setProcessGroup(cmd)
+
+	tail := &tailWriter{lines: StderrTailLines}
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, tail)
+	} else {
+		cmd.Stderr = tail
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.cmd, p.done, p.tail = cmd, make(chan struct{}), tail
+	return nil
+}
+
+func (p *Process) Wait() error {
+	p.mu.Lock()
+	cmd, done, tail := p.cmd, p.done, p.tail
+	p.mu.Unlock()
+
+	err := cmd.Wait()
+	close(done)
+
+	if err != nil && tail.String() != "" {
+		return fmt.Errorf("%w - stderr tail:\n%s", err, tail)
+	}
+	return err
+}
+
+// Stop sends SIGTERM to the process and kills it when force is closed.
+func (p *Process) Stop(force <-chan struct{}) error {
+	p.mu.Lock()
+	cmd, done := p.cmd, p.done
+	p.mu.Unlock()
+
+	if cmd == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil // not running
+	default:
+	}
+
+	if err := terminate(cmd.Process); err != nil {
+		return kill(cmd.Process)
+	}
+
+	go func() {
+		select {
+		case <-force:
+			_ = kill(cmd.Process)
+		case <-done:
+		}
+	}()
+
+	return nil
+}
+
+// Pid of the running process, 0 if not started.
+func (p *Process) Pid() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil || p.cmd.Process == nil {
+		return 0
+	}
+	return p.cmd.Process.Pid
+}
+
+// tailWriter keeps the last lines written.
+type tailWriter struct {
+	mu    sync.Mutex
+	lines int
+	buf   []byte
+}
+
+func (t *tailWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, b...)
+	// keep lines+1 newlines at most, the last line can be incomplete
+	if n := bytes.Count(t.buf, []byte("\n")); n > t.lines {
+		for ; n > t.lines; n-- {
+			t.buf = t.buf[bytes.IndexByte(t.buf, '\n')+1:]
+		}
+	}
+	return len(b), nil
+}
+
+func (t *tailWriter) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(bytes.TrimRight(t.buf, "\n"))
+}
@@ -0,0 +1,75 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Server is a supervised in process HTTP(S) server.
+// On stop the open connections are drained, and closed when forced.
+type Server struct {
+	Server   *http.Server
+	CertFile string // HTTPS when both CertFile and KeyFile are set
+	KeyFile  string
+
+	listener net.Listener
+	served   chan error
+}
+
+// NewServer returns the Server serving handler on address.
+func NewServer(address string, handler http.Handler, certFile string, keyFile string) *Server {
+	return &Server{
+		Server:   &http.Server{Addr: address, Handler: handler},
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+}
+
+// Start listens on the server address, so address errors are reported here, and serves in background.
+func (s *Server) Start() error {
+	var err error
+
+	s.listener, err = net.Listen("tcp", s.Server.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.served = make(chan error, 1)
+	go func() {
+		if s.CertFile != "" && s.KeyFile != "" {
+			s.served <- s.Server.ServeTLS(s.listener, s.CertFile, s.KeyFile)
+		} else {
+			s.served <- s.Server.Serve(s.listener)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Wait() error {
+	err := <-s.served
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Stop drains the open connections, closing them when force is closed.
+func (s *Server) Stop(force <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-force:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := s.Server.Shutdown(ctx); err != nil {
+		return s.Server.Close()
+	}
+	return nil
+}
@@ -0,0 +1,367 @@
+// Package supervisor runs the VIT services (child processes and in process HTTP servers)
+// as a group: SIGINT/SIGTERM are handled once for all of them, services are probed
+// for readiness and restarted on failure (if configured), and on stop
+// every service gets a graceful shutdown before being forced.
+package supervisor
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultStopTimeout is the time a service has to stop gracefully before being forced.
+const DefaultStopTimeout = 10 * time.Second
+
+// DefaultReadyTimeout is the time a service has to become ready after start.
+const DefaultReadyTimeout = 60 * time.Second
+
+// ReadyPollInterval is the readiness probe polling interval.
+var ReadyPollInterval = 500 * time.Millisecond
+
+// Service is a supervised service.
+type Service interface {
+	// Start the service, without waiting for it to exit.
+	// Start is called again when the service is restarted.
+	Start() error
+	// Wait for the service to exit and return its exit error, nil on clean exit.
+	Wait() error
+	// Stop gracefully the service, if running, forcing it when force is closed.
+	Stop(force <-chan struct{}) error
+}
+
+// Options of a supervised service.
+type Options struct {
+	Critical     bool          // the service exit (when not requested) ends Wait
+	Ready        func() error  // readiness probe, nil means ready once started
+	ReadyTimeout time.Duration // 0 means DefaultReadyTimeout
+	MaxRestarts  int           // restarts on exit (when not requested), 0 means no restart
+	Backoff      time.Duration // delay before the first restart, doubled on each next one
+}
+
+// Exit is the exit status of a service.
+type Exit struct {
+	Name      string
+	Err       error // nil on clean exit
+	Requested bool  // exited because stopped by the supervisor
+	Restarts  int   // number of restarts performed before this exit
+	Restart   bool  // the service is going to be restarted
+}
+
+func (e Exit) String() string {
+	status := "OK"
+	if e.Err != nil {
+		status = e.Err.Error()
+	}
+	restarts := ""
+	if e.Restarts > 0 {
+		restarts = fmt.Sprintf(" (after %d restarts)", e.Restarts)
+	}
+	switch {
+	case e.Requested:
+		return fmt.Sprintf("%s stopped%s - %s", e.Name, restarts, status)
+	case e.Restart:
+		return fmt.Sprintf("%s exited%s, restarting - %s", e.Name, restarts, status)
+	default:
+		return fmt.Sprintf("%s exited%s - %s", e.Name, restarts, status)
+	}
+}
+
+// Supervisor owns the started services.
+type Supervisor struct {
+	StopTimeout time.Duration // graceful stop timeout, before forcing the services
+	OnExit      func(Exit)    // called, if set, on each service exit (also the restarted ones)
+
+	mu       sync.Mutex
+	services []*entry
+	stopping bool
+	stop     chan struct{}
+	critical chan Exit
+	sigs     chan os.Signal
+}
+
+type entry struct {
+	name string
+	svc  Service
+	opts Options
+	done chan struct{}
+	exit Exit
+
+	// current state, guarded by Supervisor.mu
+	running  bool
+	restarts int
+	lastErr  error
+}
+
+// Status is the current state of a supervised service.
+type Status struct {
+	Name     string `json:"name"`
+	Pid      int    `json:"pid"` // the supervisor one for the in process services
+	Running  bool   `json:"running"`
+	Restarts int    `json:"restarts"`
+	LastErr  string `json:"last_error,omitempty"` // last exit error, if any
+}
+
+// New returns a Supervisor listening for SIGINT/SIGTERM.
+func New(stopTimeout time.Duration) *Supervisor {
+	if stopTimeout <= 0 {
+		stopTimeout = DefaultStopTimeout
+	}
+	s := &Supervisor{
+		StopTimeout: stopTimeout,
+		stop:        make(chan struct{}),
+		critical:    make(chan Exit, 1),
+		sigs:        make(chan os.Signal, 2),
+	}
+	signal.Notify(s.sigs, syscall.SIGINT, syscall.SIGTERM)
+	return s
+}
+
+// Start starts svc, waits for it to be ready and supervises it.
+// An error is returned if the service fails to start or to become ready.
+func (s *Supervisor) Start(name string, svc Service, opts Options) error {
+	if s.isStopping() {
+		return fmt.Errorf("%s: %s", name, "supervisor is stopping")
+	}
+
+	exited, err := startReady(svc, opts, s.stop)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	e := &entry{name: name, svc: svc, opts: opts, done: make(chan struct{}), running: true}
+	s.mu.Lock()
+	s.services = append(s.services, e)
+	s.mu.Unlock()
+
+	go s.supervise(e, exited)
+
+	return nil
+}
+
+// startReady starts svc and waits for the readiness probe to succeed,
+// giving up when stop is closed. The returned channel reports the service exit.
+func startReady(svc Service, opts Options, stop <-chan struct{}) (<-chan error, error) {
+	if err := svc.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- svc.Wait()
+	}()
+
+	if opts.Ready == nil {
+		return exited, nil
+	}
+
+	timeout := opts.ReadyTimeout
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(ReadyPollInterval)
+	defer ticker.Stop()
+
+	for {
+		probeErr := opts.Ready()
+		if probeErr == nil {
+			return exited, nil
+		}
+
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = fmt.Errorf("exited")
+			}
+			return nil, fmt.Errorf("%s: %w", "failed before being ready", err)
+		case <-deadline.C:
+			_ = svc.Stop(closed)
+			err := <-exited
+			return nil, fmt.Errorf("not ready after %s (%s): %v", timeout, probeErr, err)
+		case <-stop:
+			_ = svc.Stop(closed)
+			err := <-exited
+			return nil, fmt.Errorf("stopped before being ready: %v", err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// supervise waits for the service exit, restarting it when allowed.
+func (s *Supervisor) supervise(e *entry, exited <-chan error) {
+	restarts := 0
+
+	for {
+		err := <-exited
+		exit := Exit{Name: e.name, Err: err, Requested: s.isStopping(), Restarts: restarts}
+
+		s.mu.Lock()
+		e.running, e.lastErr = false, err
+		s.mu.Unlock()
+
+		if exit.Requested || restarts >= e.opts.MaxRestarts {
+			s.mu.Lock()
+			e.exit = exit
+			s.mu.Unlock()
+			close(e.done)
+
+			if s.OnExit != nil {
+				s.OnExit(exit)
+			}
+			if e.opts.Critical && !exit.Requested {
+				select {
+				case s.critical <- exit:
+				default:
+				}
+			}
+			return
+		}
+
+		exit.Restart = true
+		if s.OnExit != nil {
+			s.OnExit(exit)
+		}
+
+		restarts++
+		delay := e.opts.Backoff << uint(restarts-1)
+		log.Printf("***** %s restart %d/%d in %s", e.name, restarts, e.opts.MaxRestarts, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-s.stop:
+			exited = exitedWith(err)
+			continue
+		}
+
+		ch, err := startReady(e.svc, e.opts, s.stop)
+		if err != nil {
+			exited = exitedWith(err)
+			continue
+		}
+		exited = ch
+		s.mu.Lock()
+		e.running, e.restarts = true, restarts
+		s.mu.Unlock()
+		log.Printf("%s restarted", e.name)
+
+		if s.isStopping() {
+			_ = e.svc.Stop(closed) // Stop started meanwhile
+		}
+	}
+}
+
+func exitedWith(err error) <-chan error {
+	c := make(chan error, 1)
+	c <- err
+	return c
+}
+
+// closed is an already closed channel, to force a stop.
+var closed = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+func (s *Supervisor) isStopping() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopping
+}
+
+// Status returns the state of the started services, in start order.
+func (s *Supervisor) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := make([]Status, len(s.services))
+	for i, e := range s.services {
+		status[i] = Status{
+			Name:     e.name,
+			Running:  e.running,
+			Restarts: e.restarts,
+		}
+		if e.lastErr != nil {
+			status[i].LastErr = e.lastErr.Error()
+		}
+		if !e.running {
+			continue
+		}
+		status[i].Pid = os.Getpid()
+		if p, ok := e.svc.(interface{ Pid() int }); ok {
+			status[i].Pid = p.Pid()
+		}
+	}
+	return status
+}
+
+// Wait blocks until SIGINT/SIGTERM is received (nil returned)
+// or a critical service exits (its exit returned as error).
+func (s *Supervisor) Wait() error {
+	select {
+	case sig := <-s.sigs:
+		log.Printf("received %s", sig)
+		return nil
+	case exit := <-s.critical:
+		return fmt.Errorf("%s", exit)
+	}
+}
+
+// Stop stops the running services, in reverse start order, and returns the exit status
+// of all the services. Services still running after StopTimeout (or on a new SIGINT/SIGTERM)
+// are forced to stop.
+func (s *Supervisor) Stop() []Exit {
+	s.mu.Lock()
+	if !s.stopping {
+		s.stopping = true
+		close(s.stop)
+	}
+	services := make([]*entry, len(s.services))
+	copy(services, s.services)
+	s.mu.Unlock()
+
+	force := make(chan struct{})
+	timer := time.AfterFunc(s.StopTimeout, func() { close(force) })
+	defer timer.Stop()
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-s.sigs:
+			if timer.Stop() {
+				close(force)
+			}
+		case <-stopped:
+		}
+	}()
+
+	for i := len(services) - 1; i >= 0; i-- {
+		e := services[i]
+		select {
+		case <-e.done:
+			continue
+		default:
+		}
+		if err := e.svc.Stop(force); err != nil {
+			log.Printf("***** %s stop: %s", e.name, err)
+		}
+		<-e.done
+	}
+
+	signal.Stop(s.sigs)
+
+	exits := make([]Exit, len(services))
+	s.mu.Lock()
+	for i, e := range services {
+		exits[i] = e.exit
+	}
+	s.mu.Unlock()
+	return exits
+}
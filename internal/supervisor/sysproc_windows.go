@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package supervisor
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate is not supported, the process is killed instead.
+func terminate(p *os.Process) error {
+	return errors.New("SIGTERM not supported")
+}
+
+func kill(p *os.Process) error {
+	return p.Kill()
+}
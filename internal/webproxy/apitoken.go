@@ -0,0 +1,47 @@
+package webproxy
+
+import (
+	"net/http"
+)
+
+// ApiTokenHeader is the request header carrying the vit-servicing-station api token.
+const ApiTokenHeader = "API-Token"
+
+// ApiTokens validates the API-Token header of the /api/v0 requests.
+// A nil *ApiTokens, or one without tokens, accepts every request.
+type ApiTokens struct {
+	Tokens map[string]bool
+	Exempt map[string]bool // /api/v0 routes served without token, ex: "block0"
+}
+
+// NewApiTokens returns the ApiTokens accepting tokens,
+// the exempt routes are served also without a valid token.
+func NewApiTokens(tokens []string, exempt []string) *ApiTokens {
+	t := &ApiTokens{
+		Tokens: make(map[string]bool, len(tokens)),
+		Exempt: make(map[string]bool, len(exempt)),
+	}
+	for _, token := range tokens {
+		t.Tokens[token] = true
+	}
+	for _, route := range exempt {
+		t.Exempt[route] = true
+	}
+	return t
+}
+
+// Allowed reports whether req, to the /api/v0 route, can be served.
+func (t *ApiTokens) Allowed(route string, req *http.Request) bool {
+	if t == nil || len(t.Tokens) == 0 || t.Exempt[route] {
+		return true
+	}
+	return t.Tokens[req.Header.Get(ApiTokenHeader)]
+}
+
+// unauthorized replies to a request without a valid API-Token.
+func unauthorized(res http.ResponseWriter, req *http.Request) {
+	corsHeaders(res, req)
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusUnauthorized)
+	res.Write([]byte(`{"error": "missing or invalid ` + ApiTokenHeader + `"}`))
+}
@@ -0,0 +1,88 @@
+package webproxy
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// PhaseSource provides the voting phase served by the proxy.
+type PhaseSource interface {
+	// Current returns the current phase.
+	Current() interface{}
+	// Subscribe returns the channel receiving the phase on each transition,
+	// closed by cancel or when the source stops.
+	Subscribe() (events <-chan interface{}, cancel func())
+}
+
+type PhaseHandler struct {
+	*Proxy
+}
+
+// ServeHTTP serves the current phase (/) and the phase transitions as
+// server-sent events (/events), the current phase being the first event.
+func (h *PhaseHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if h.Phases == nil {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+	if req.Method != "GET" {
+		http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch req.URL.Path {
+	case "/":
+		resData, err := json.MarshalIndent(h.Phases.Current(), "", "  ")
+		if err != nil {
+			res.Header().Set("Content-Type", "application/json")
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write([]byte(`{"error": "error marshalling data"}`))
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		corsHeaders(res, req)
+		res.WriteHeader(http.StatusOK)
+		res.Write(resData)
+	case "/events":
+		h.serveEvents(res, req)
+	default:
+		http.Error(res, "Not Found", http.StatusNotFound)
+	}
+}
+
+func (h *PhaseHandler) serveEvents(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		http.Error(res, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, cancel := h.Phases.Subscribe()
+	defer cancel()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	corsHeaders(res, req)
+	res.WriteHeader(http.StatusOK)
+
+	event := h.Phases.Current()
+	for {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		res.Write([]byte("event: phase\ndata: "))
+		res.Write(data)
+		res.Write([]byte("\n\n"))
+		flusher.Flush()
+
+		select {
+		case event, ok = <-events:
+			if !ok {
+				return // source stopped
+			}
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package webproxy
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/input-output-hk/jorvit/internal/datastore"
+	"github.com/input-output-hk/jorvit/internal/loader"
+)
+
+// proposalsQuery filters, sorts and paginates the store proposals based on the request query:
+//
+//	category     - category name (case insensitive)
+//	challenge_id - challenge id
+//	fund_id      - fund id
+//	vote_type    - public or private
+//	voteplan_id  - chain voteplan id
+//	search       - text contained in title or summary (case insensitive)
+//	sort         - funds or impact_score, prefixed with "-" for descending order
+//	limit        - max number of proposals returned (0 means all)
+//	offset       - number of proposals to skip
+//
+// It returns the requested page and the total number of proposals matching the filters.
+func proposalsQuery(store datastore.ProposalsStore, q url.Values) (*[]*loader.ProposalData, int, error) {
+	var (
+		filters []func(*loader.ProposalData) bool
+		err     error
+		list    = store.All()
+	)
+
+	if v := q.Get("category"); v != "" {
+		filters = append(filters, func(p *loader.ProposalData) bool {
+			return strings.EqualFold(p.CategoryName, v)
+		})
+	}
+	if v := q.Get("challenge_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid %s", "challenge_id")
+		}
+		list = store.SearchChallengeID(uint32(id)) // use the index as starting list
+	}
+	if v := q.Get("fund_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid %s", "fund_id")
+		}
+		filters = append(filters, func(p *loader.ProposalData) bool {
+			return p.ChainVotePlan != nil && p.ChainVotePlan.FundID == id
+		})
+	}
+	if v := q.Get("vote_type"); v != "" {
+		if v != "public" && v != "private" {
+			return nil, 0, fmt.Errorf("invalid %s", "vote_type")
+		}
+		filters = append(filters, func(p *loader.ProposalData) bool {
+			return p.VoteType == v
+		})
+	}
+	if v := q.Get("voteplan_id"); v != "" {
+		filters = append(filters, func(p *loader.ProposalData) bool {
+			return p.ChainVotePlan != nil && p.ChainVotePlan.VotePlanID == v
+		})
+		if q.Get("challenge_id") == "" {
+			list = store.SearchVotePlanID(v) // use the index as starting list
+		}
+	}
+	if v := strings.ToLower(q.Get("search")); v != "" {
+		filters = append(filters, func(p *loader.ProposalData) bool {
+			return strings.Contains(strings.ToLower(p.Title), v) || strings.Contains(strings.ToLower(p.Summary), v)
+		})
+	}
+
+	ret := datastore.Filter(list, func(p *loader.ProposalData) bool {
+		for _, f := range filters {
+			if !f(p) {
+				return false
+			}
+		}
+		return true
+	})
+
+	if v := q.Get("sort"); v != "" {
+		desc := strings.HasPrefix(v, "-")
+		var less func(a, b *loader.ProposalData) bool
+		switch strings.TrimPrefix(v, "-") {
+		case "funds":
+			less = func(a, b *loader.ProposalData) bool { return a.Funds < b.Funds }
+		case "impact_score":
+			less = func(a, b *loader.ProposalData) bool { return a.ImpactScore < b.ImpactScore }
+		default:
+			return nil, 0, fmt.Errorf("invalid %s", "sort")
+		}
+		sort.SliceStable(*ret, func(i, j int) bool {
+			if desc {
+				return less((*ret)[j], (*ret)[i])
+			}
+			return less((*ret)[i], (*ret)[j])
+		})
+	}
+
+	total := len(*ret)
+
+	limit, offset := 0, 0
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 0 {
+			return nil, 0, fmt.Errorf("invalid %s", "limit")
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			return nil, 0, fmt.Errorf("invalid %s", "offset")
+		}
+	}
+
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	page := (*ret)[offset:end]
+
+	return &page, total, nil
+}
@@ -0,0 +1,68 @@
+package webproxy
+
+import (
+	"net/http"
+)
+
+// Station serves the vit-servicing-station REST api from the proxy data,
+// so a complete station is available also without the station binaries.
+// Only the station endpoints are served, nothing is forwarded to the node.
+type Station struct {
+	*Proxy
+	V0Handler *V0Handler
+}
+
+// NewStation returns a Station serving the p data.
+func NewStation(p *Proxy) *Station {
+	return &Station{
+		Proxy:     p,
+		V0Handler: p.v0Handler(),
+	}
+}
+
+func (h *Station) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method == "OPTIONS" {
+		corsHeaders(res, req)
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var head string
+	head, req.URL.Path = ShiftPath(req.URL.Path)
+	if head != "api" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	head, req.URL.Path = ShiftPath(req.URL.Path)
+	switch head {
+	case "health":
+		h.health(res, req)
+		return
+	case "v0":
+	default:
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	head, _ = ShiftPath(req.URL.Path)
+	switch head {
+	case "health":
+		h.health(res, req)
+	case "proposals", "block0", "fund", "funds", "challenges":
+		h.V0Handler.ServeHTTP(res, req) // API-Token checked here
+	default:
+		http.Error(res, "Not Found", http.StatusNotFound)
+	}
+}
+
+// health replies OK as long as the station is serving.
+func (h *Station) health(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET", "HEAD":
+		corsHeaders(res, req)
+		res.WriteHeader(http.StatusOK)
+	default:
+		http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+	}
+}
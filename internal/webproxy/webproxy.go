@@ -10,14 +10,21 @@ import (
 	"strings"
 
 	"github.com/input-output-hk/jorvit/internal/datastore"
+	"github.com/input-output-hk/jorvit/internal/loader"
 )
 
-var (
-	reverseProxyAddress = "http://127.0.0.1:8001"
-	proposals           datastore.ProposalsStore
-	funds               datastore.FundsStore
-	block0Bin           *[]byte
-)
+// Proxy contains the data served by the proxy handlers
+// and the address of the node REST api the other requests are forwarded to.
+type Proxy struct {
+	Proposals           datastore.ProposalsStore
+	Funds               datastore.FundsStore
+	Challenges          datastore.ChallengesStore
+	Block0Bin           *[]byte
+	ReverseProxyAddress string             // ex: "http://127.0.0.1:8001"
+	ApiTokens           *ApiTokens         // nil means no API-Token check
+	Status              func() interface{} // jorvit status, nil means not served
+	Phases              PhaseSource        // voting phase, nil means not served
+}
 
 // ShiftPath splits off the first component of p, which will be cleaned of
 // relative components before processing. head will never contain a slash and
@@ -32,6 +39,7 @@ func ShiftPath(p string) (head, tail string) {
 }
 
 type App struct {
+	*Proxy
 	// Not using http.Handler for decoupling
 	ApiHandler *ApiHandler
 }
@@ -51,7 +59,7 @@ func (h *App) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		h.ApiHandler.ServeHTTP(res, req)
 		return
 	case "explorer":
-		serveReverseProxy("/explorer", res, req)
+		h.serveReverseProxy("/explorer", res, req)
 	default:
 		http.Error(res, "Not Found", http.StatusNotFound)
 		return
@@ -77,15 +85,24 @@ func (h *ApiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 }
 
 type V0Handler struct {
-	ProposalHandler *ProposalHandler
-	Block0Handler   *Block0Handler
-	FundInfoHandler *FundInfoHandler
+	*Proxy
+	ProposalHandler  *ProposalHandler
+	Block0Handler    *Block0Handler
+	FundInfoHandler  *FundInfoHandler
+	FundListAll      *FundListAll
+	ChallengeHandler *ChallengeHandler
+	JorvitHandler    *JorvitHandler
 }
 
 func (h *V0Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	var head string
 	head, req.URL.Path = ShiftPath(req.URL.Path)
 
+	if !h.ApiTokens.Allowed(head, req) {
+		unauthorized(res, req)
+		return
+	}
+
 	switch head {
 	case "proposals":
 		h.ProposalHandler.ServeHTTP(res, req)
@@ -95,25 +112,35 @@ func (h *V0Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		return
 	case "fund":
 		h.FundInfoHandler.ServeHTTP(res, req)
+		return
+	case "funds":
+		h.FundListAll.ServeHTTP(res, req)
+		return
+	case "challenges":
+		h.ChallengeHandler.ServeHTTP(res, req)
+		return
+	case "jorvit":
+		h.JorvitHandler.ServeHTTP(res, req)
+		return
 	case "account":
-		serveReverseProxy("/api/v0/account", res, req)
+		h.serveReverseProxy("/api/v0/account", res, req)
 		return
 	case "block":
-		serveReverseProxy("/api/v0/block", res, req)
+		h.serveReverseProxy("/api/v0/block", res, req)
 		return
 	case "fragment":
-		serveReverseProxy("/api/v0/fragment", res, req)
+		h.serveReverseProxy("/api/v0/fragment", res, req)
 		return
 	case "message":
-		serveReverseProxy("/api/v0/message", res, req)
+		h.serveReverseProxy("/api/v0/message", res, req)
 		return
 	case "settings":
-		serveReverseProxy("/api/v0/settings", res, req)
+		h.serveReverseProxy("/api/v0/settings", res, req)
 	case "vote":
-		serveReverseProxy("/api/v0/vote", res, req)
+		h.serveReverseProxy("/api/v0/vote", res, req)
 		return
 	case "fragments":
-		serveReverseProxy("/api/v1/fragments", res, req)
+		h.serveReverseProxy("/api/v1/fragments", res, req)
 		return
 	default:
 		http.Error(res, "Not Found", http.StatusNotFound)
@@ -151,26 +178,35 @@ func (h *ProposalHandler) ServeHTTP(res http.ResponseWriter, req *http.Request)
 	}
 }
 
-type ProposalListAll struct{}
+type ProposalListAll struct {
+	*Proxy
+}
 
 func (h *ProposalListAll) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "application/json")
-	_ = req.URL.Query()
 
 	switch req.Method {
 	case "GET":
-		if proposals.Total() == 0 {
+		if h.Proposals.Total() == 0 {
 			res.WriteHeader(http.StatusNotFound)
 			res.Write([]byte(`{"error": "empty data"}`))
 			return
 		}
-		resData, err := json.MarshalIndent(proposals.All(), "", "  ")
+		list, total, err := proposalsQuery(h.Proposals, req.URL.Query())
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			res.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			return
+		}
+		resData, err := json.MarshalIndent(list, "", "  ")
 		if err != nil {
 			res.WriteHeader(http.StatusInternalServerError)
 			res.Write([]byte(`{"error": "error marshalling data"}`))
 			return
 		}
 		corsHeaders(res, req)
+		res.Header().Set("X-Total-Count", strconv.Itoa(total))
+		res.Header().Set("Access-Control-Expose-Headers", "X-Total-Count")
 		res.WriteHeader(http.StatusOK)
 		res.Write(resData)
 		return
@@ -179,7 +215,9 @@ func (h *ProposalListAll) ServeHTTP(res http.ResponseWriter, req *http.Request)
 	}
 }
 
-type ProposalListSingle struct{}
+type ProposalListSingle struct {
+	*Proxy
+}
 
 func (h *ProposalListSingle) Handler(internalID string, res http.ResponseWriter, req *http.Request) http.Handler {
 	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
@@ -188,7 +226,7 @@ func (h *ProposalListSingle) Handler(internalID string, res http.ResponseWriter,
 
 		switch req.Method {
 		case "GET":
-			proposal := proposals.SearchID(internalID)
+			proposal := h.Proposals.SearchID(internalID)
 			if proposal == nil {
 				res.WriteHeader(http.StatusNotFound)
 				res.Write([]byte(`{"error": not found"}`))
@@ -210,34 +248,54 @@ func (h *ProposalListSingle) Handler(internalID string, res http.ResponseWriter,
 	})
 }
 
-type Block0Handler struct{}
+type Block0Handler struct {
+	*Proxy
+}
 
 func (h *Block0Handler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "application/octet-stream")
-	res.Header().Set("Content-Length", strconv.Itoa(len(*block0Bin)))
+	res.Header().Set("Content-Length", strconv.Itoa(len(*h.Block0Bin)))
 	switch req.Method {
 	case "GET":
 		corsHeaders(res, req)
 		res.WriteHeader(http.StatusOK)
-		res.Write(*block0Bin)
+		res.Write(*h.Block0Bin)
 		return
 	default:
 		http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-type FundInfoHandler struct{}
+type FundInfoHandler struct {
+	FundListSingle *FundListSingle
+}
 
 func (h *FundInfoHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head, fundID string
+	head, req.URL.Path = ShiftPath(req.URL.Path)
+	fundID = head
+
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+	h.FundListSingle.Handler(fundID, res, req).ServeHTTP(res, req)
+}
+
+type FundListAll struct {
+	*Proxy
+}
+
+func (h *FundListAll) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	res.Header().Set("Content-Type", "application/json")
 	switch req.Method {
 	case "GET":
-		if funds.Total() == 0 {
+		if h.Funds.Total() == 0 {
 			res.WriteHeader(http.StatusNotFound)
 			res.Write([]byte(`{"error": "empty data"}`))
 			return
 		}
-		resData, err := json.MarshalIndent(funds.First(), "", "  ")
+		resData, err := json.MarshalIndent(h.Funds.All(), "", "  ")
 		if err != nil {
 			res.WriteHeader(http.StatusInternalServerError)
 			res.Write([]byte(`{"error": "error marshalling data"}`))
@@ -252,42 +310,232 @@ func (h *FundInfoHandler) ServeHTTP(res http.ResponseWriter, req *http.Request)
 	}
 }
 
-func Run(p datastore.ProposalsStore, f datastore.FundsStore, block0 *[]byte, address string, revProxyAddr string) error {
-	proposals = p
-	funds = f
-	reverseProxyAddress = revProxyAddr
-	block0Bin = block0
+type FundListSingle struct {
+	*Proxy
+}
 
-	app := &App{
-		ApiHandler: &ApiHandler{
-			V0Handler: &V0Handler{
-				ProposalHandler: &ProposalHandler{
-					ProposalListAll:    new(ProposalListAll),
-					ProposalListSingle: new(ProposalListSingle),
+// Handler serves the fund with fundID, or the first one (current fund) when fundID is empty.
+func (h *FundListSingle) Handler(fundID string, res http.ResponseWriter, req *http.Request) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case "GET":
+			if h.Funds.Total() == 0 {
+				res.WriteHeader(http.StatusNotFound)
+				res.Write([]byte(`{"error": "empty data"}`))
+				return
+			}
+			fund := h.Funds.First()
+			if fundID != "" {
+				fund = h.Funds.SearchID(fundID)
+			}
+			if fund == nil {
+				res.WriteHeader(http.StatusNotFound)
+				res.Write([]byte(`{"error": "not found"}`))
+				return
+			}
+			resData, err := json.MarshalIndent(fund, "", "  ")
+			if err != nil {
+				res.WriteHeader(http.StatusInternalServerError)
+				res.Write([]byte(`{"error": "error marshalling data"}`))
+				return
+			}
+			corsHeaders(res, req)
+			res.WriteHeader(http.StatusOK)
+			res.Write(resData)
+			return
+		default:
+			http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+type ChallengeHandler struct {
+	ChallengeListAll    *ChallengeListAll
+	ChallengeListSingle *ChallengeListSingle
+}
+
+func (h *ChallengeHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head, challengeID string
+	head, req.URL.Path = ShiftPath(req.URL.Path)
+	challengeID = head
+
+	if req.URL.Path != "/" {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
+	}
+	switch challengeID {
+	case "":
+		h.ChallengeListAll.ServeHTTP(res, req)
+	default:
+		h.ChallengeListSingle.Handler(challengeID, res, req).ServeHTTP(res, req)
+	}
+}
+
+type ChallengeListAll struct {
+	*Proxy
+}
+
+func (h *ChallengeListAll) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Content-Type", "application/json")
+	switch req.Method {
+	case "GET":
+		if h.Challenges.Total() == 0 {
+			res.WriteHeader(http.StatusNotFound)
+			res.Write([]byte(`{"error": "empty data"}`))
+			return
+		}
+		resData, err := json.MarshalIndent(h.Challenges.All(), "", "  ")
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write([]byte(`{"error": "error marshalling data"}`))
+			return
+		}
+		corsHeaders(res, req)
+		res.WriteHeader(http.StatusOK)
+		res.Write(resData)
+		return
+	default:
+		http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type ChallengeListSingle struct {
+	*Proxy
+}
+
+// challengeProposals is the challenge details together with its proposals.
+type challengeProposals struct {
+	*loader.ChallengeData
+	Proposals *[]*loader.ProposalData `json:"proposals"`
+}
+
+func (h *ChallengeListSingle) Handler(challengeID string, res http.ResponseWriter, req *http.Request) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		switch req.Method {
+		case "GET":
+			challenge := h.Challenges.SearchID(challengeID)
+			if challenge == nil {
+				res.WriteHeader(http.StatusNotFound)
+				res.Write([]byte(`{"error": "not found"}`))
+				return
+			}
+			resData, err := json.MarshalIndent(
+				challengeProposals{
+					ChallengeData: challenge,
+					Proposals:     h.Proposals.SearchChallengeID(challenge.ID),
 				},
-				Block0Handler:   new(Block0Handler),
-				FundInfoHandler: new(FundInfoHandler),
-			},
-		},
+				"", "  ",
+			)
+			if err != nil {
+				res.WriteHeader(http.StatusInternalServerError)
+				res.Write([]byte(`{"error": "error marshalling data"}`))
+				return
+			}
+			corsHeaders(res, req)
+			res.WriteHeader(http.StatusOK)
+			res.Write(resData)
+			return
+		default:
+			http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+type JorvitHandler struct {
+	StatusHandler *StatusHandler
+	PhaseHandler  *PhaseHandler
+}
+
+func (h *JorvitHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	var head string
+	head, req.URL.Path = ShiftPath(req.URL.Path)
+
+	switch head {
+	case "status":
+		if req.URL.Path != "/" {
+			http.Error(res, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.StatusHandler.ServeHTTP(res, req)
+	case "phase":
+		h.PhaseHandler.ServeHTTP(res, req)
+	default:
+		http.Error(res, "Not Found", http.StatusNotFound)
 	}
+}
+
+type StatusHandler struct {
+	*Proxy
+}
 
-	srv := &http.Server{
-		Addr:    address,
-		Handler: app,
+func (h *StatusHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if h.Status == nil {
+		http.Error(res, "Not Found", http.StatusNotFound)
+		return
 	}
+	res.Header().Set("Content-Type", "application/json")
+	switch req.Method {
+	case "GET":
+		resData, err := json.MarshalIndent(h.Status(), "", "  ")
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			res.Write([]byte(`{"error": "error marshalling data"}`))
+			return
+		}
+		corsHeaders(res, req)
+		res.WriteHeader(http.StatusOK)
+		res.Write(resData)
+		return
+	default:
+		http.Error(res, "Only GET is allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-	return srv.ListenAndServe()
+// Handler returns the proxy http handler.
+func (p *Proxy) Handler() http.Handler {
+	return &App{
+		Proxy: p,
+		ApiHandler: &ApiHandler{
+			V0Handler: p.v0Handler(),
+		},
+	}
+}
+
+// v0Handler returns the /api/v0 handler.
+func (p *Proxy) v0Handler() *V0Handler {
+	return &V0Handler{
+		Proxy: p,
+		ProposalHandler: &ProposalHandler{
+			ProposalListAll:    &ProposalListAll{p},
+			ProposalListSingle: &ProposalListSingle{p},
+		},
+		Block0Handler: &Block0Handler{p},
+		FundInfoHandler: &FundInfoHandler{
+			FundListSingle: &FundListSingle{p},
+		},
+		FundListAll: &FundListAll{p},
+		ChallengeHandler: &ChallengeHandler{
+			ChallengeListAll:    &ChallengeListAll{p},
+			ChallengeListSingle: &ChallengeListSingle{p},
+		},
+		JorvitHandler: &JorvitHandler{
+			StatusHandler: &StatusHandler{p},
+			PhaseHandler:  &PhaseHandler{p},
+		},
+	}
 }
 
 // serveReverseProxy - Serve a reverse proxy for a given url
-func serveReverseProxy(target string, res http.ResponseWriter, req *http.Request) {
-	url, _ := url.Parse(reverseProxyAddress + target)
+func (p *Proxy) serveReverseProxy(target string, res http.ResponseWriter, req *http.Request) {
+	url, _ := url.Parse(p.ReverseProxyAddress + target)
 
 	proxy := httputil.NewSingleHostReverseProxy(url)
 	proxy.ModifyResponse = proxyResHeaders
 
 	if _, ok := req.Header["Origin"]; ok {
-		req.Header["Origin"][0] = reverseProxyAddress // "http://127.0.0.1:8001"
+		req.Header["Origin"][0] = p.ReverseProxyAddress // "http://127.0.0.1:8001"
 	}
 
 	// SSL redirection
@@ -305,7 +553,7 @@ func corsHeaders(res http.ResponseWriter, req *http.Request) {
 		headers := res.Header()
 		headers.Set("Access-Control-Allow-Origin", "*")
 		headers.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, HEAD")
-		headers.Set("Access-Control-Allow-Headers", "Authorization, Origin, X-Requested-With, Content-Type, Accept")
+		headers.Set("Access-Control-Allow-Headers", "Authorization, Origin, X-Requested-With, Content-Type, Accept, "+ApiTokenHeader)
 	} else if _, ok := req.Header["Origin"]; ok {
 		headers := res.Header()
 		headers.Set("Access-Control-Allow-Origin", "*")
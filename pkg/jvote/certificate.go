@@ -0,0 +1,70 @@
+package jvote
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+// CertificateNewVoteTallyPublic - create a public vote tally certificate.
+//
+// jcli certificate new vote-tally public --vote-plan-id <id> [--output <FILE_OUTPUT>] | STDOUT
+func CertificateNewVoteTallyPublic(
+	votePlanID string,
+	outputFile string,
+) ([]byte, error) {
+	if votePlanID == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "votePlanID")
+	}
+
+	arg := []string{
+		"certificate", "new", "vote-tally", "public",
+		"--vote-plan-id", votePlanID,
+	}
+	if outputFile != "" {
+		arg = append(arg, "--output", outputFile)
+	}
+
+	out, err := jcli(nil, arg...)
+	if err != nil || outputFile == "" {
+		return out, err
+	}
+
+	return ioutil.ReadFile(outputFile)
+}
+
+// CertificateNewVoteTallyPrivate - create a private vote tally certificate, containing the decrypted tally.
+//
+// jcli certificate new vote-tally private --vote-plan <vote plan status file> --vote-plan-id <id> --shares <merged shares file> [--output <FILE_OUTPUT>] | STDOUT
+func CertificateNewVoteTallyPrivate(
+	votePlanFile string,
+	votePlanID string,
+	sharesFile string,
+	outputFile string,
+) ([]byte, error) {
+	if votePlanFile == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "votePlanFile")
+	}
+	if votePlanID == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "votePlanID")
+	}
+	if sharesFile == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "sharesFile")
+	}
+
+	arg := []string{
+		"certificate", "new", "vote-tally", "private",
+		"--vote-plan", votePlanFile,
+		"--vote-plan-id", votePlanID,
+		"--shares", sharesFile,
+	}
+	if outputFile != "" {
+		arg = append(arg, "--output", outputFile)
+	}
+
+	out, err := jcli(nil, arg...)
+	if err != nil || outputFile == "" {
+		return out, err
+	}
+
+	return ioutil.ReadFile(outputFile)
+}
@@ -0,0 +1,22 @@
+package jvote
+
+import "fmt"
+
+// RestVotePlans - Get the active vote plans status (needed by the private tally).
+//
+// jcli rest v0 vote active plans get --host <host> [--output-format <format>] | STDOUT
+func RestVotePlans(
+	host string,
+	outputFormat string,
+) ([]byte, error) {
+	if host == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "host")
+	}
+
+	arg := []string{"rest", "v0", "vote", "active", "plans", "get", "--host", host}
+	if outputFormat != "" {
+		arg = append(arg, "--output-format", outputFormat)
+	}
+
+	return jcli(nil, arg...)
+}
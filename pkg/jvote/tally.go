@@ -0,0 +1,86 @@
+package jvote
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// TallyDecryptionShares - create the decryption share of a committee member for the private voteplan.
+//
+// jcli votes tally decryption-shares --vote-plan <vote plan status file> --vote-plan-id <id> --key <member secret key file> | STDOUT
+func TallyDecryptionShares(
+	votePlanFile string,
+	votePlanID string,
+	keyFile string,
+) ([]byte, error) {
+	if votePlanFile == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "votePlanFile")
+	}
+	if votePlanID == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "votePlanID")
+	}
+	if keyFile == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "keyFile")
+	}
+
+	arg := []string{
+		"votes", "tally", "decryption-shares",
+		"--vote-plan", votePlanFile,
+		"--vote-plan-id", votePlanID,
+		"--key", keyFile,
+	}
+
+	return jcli(nil, arg...)
+}
+
+// TallyMergeShares - merge the committee members decryption shares.
+//
+// jcli votes tally merge-shares <shares file>... | STDOUT
+func TallyMergeShares(
+	sharesFiles []string,
+) ([]byte, error) {
+	if len(sharesFiles) == 0 {
+		return nil, fmt.Errorf("parameter missing : %s", "sharesFiles")
+	}
+
+	arg := append([]string{"votes", "tally", "merge-shares"}, sharesFiles...)
+
+	return jcli(nil, arg...)
+}
+
+// TallyDecryptResults - decrypt the private voteplan tally with the merged shares.
+//
+// jcli votes tally decrypt-results --vote-plan <vote plan status file> --vote-plan-id <id> --shares <merged shares file> --threshold <n> [--output-format <format>] | STDOUT
+func TallyDecryptResults(
+	votePlanFile string,
+	votePlanID string,
+	sharesFile string,
+	threshold uint,
+	outputFormat string,
+) ([]byte, error) {
+	if votePlanFile == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "votePlanFile")
+	}
+	if votePlanID == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "votePlanID")
+	}
+	if sharesFile == "" {
+		return nil, fmt.Errorf("parameter missing : %s", "sharesFile")
+	}
+	if threshold == 0 {
+		return nil, fmt.Errorf("parameter missing : %s", "threshold")
+	}
+
+	arg := []string{
+		"votes", "tally", "decrypt-results",
+		"--vote-plan", votePlanFile,
+		"--vote-plan-id", votePlanID,
+		"--shares", sharesFile,
+		"--threshold", strconv.FormatUint(uint64(threshold), 10),
+	}
+	if outputFormat != "" {
+		arg = append(arg, "--output-format", outputFormat)
+	}
+
+	return jcli(nil, arg...)
+}
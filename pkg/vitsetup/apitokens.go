@@ -0,0 +1,92 @@
+package vitsetup
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/input-output-hk/jorvit/internal/webproxy"
+	"github.com/input-output-hk/jorvit/pkg/vcli"
+)
+
+// apiTokenSize is the size (bytes) of the generated API-Token values, same of vit-servicing-station-cli.
+const apiTokenSize = 10
+
+// BuildApiTokens checks the provided API-Token values, generates the requested ones
+// and writes them all to the working dir (api_tokens.txt, one per line).
+// Generated tokens are derived from the seed in reproducible mode,
+// otherwise vit-servicing-station-cli is used when available.
+func (env *Env) BuildApiTokens() error {
+	env.ApiTokens = make([]string, 0, len(env.Config.Station.ApiTokens)+int(env.Config.Station.ApiTokensGenerate))
+
+	for _, token := range env.Config.Station.ApiTokens {
+		if _, err := base64.RawURLEncoding.DecodeString(token); err != nil || token == "" {
+			return fmt.Errorf("api token [%s] - expected URL safe base64 (no padding)", token)
+		}
+		env.ApiTokens = append(env.ApiTokens, token)
+	}
+
+	generated, err := env.generateApiTokens(int(env.Config.Station.ApiTokensGenerate))
+	if err != nil {
+		return err
+	}
+	env.ApiTokens = append(env.ApiTokens, generated...)
+
+	if len(env.ApiTokens) == 0 {
+		return nil
+	}
+
+	env.ApiTokensFile = filepath.Join(env.WorkingDir, "api_tokens.txt")
+	if err = ioutil.WriteFile(env.ApiTokensFile, []byte(strings.Join(env.ApiTokens, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("%s %s: %w", "api tokens ioutil.WriteFile", env.ApiTokensFile, err)
+	}
+
+	return nil
+}
+
+func (env *Env) generateApiTokens(n int) ([]string, error) {
+	if n == 0 {
+		return nil, nil
+	}
+
+	if env.VcliBin != "" && !env.Config.IsReproducible() {
+		out, err := vcli.ApiTokenGenerate(n, apiTokenSize)
+		if err != nil {
+			return nil, cmdErr(err, "vcli.ApiTokenGenerate", out)
+		}
+		tokens := strings.Fields(string(out))
+		if len(tokens) != n {
+			return nil, fmt.Errorf("vcli.ApiTokenGenerate - expected %d tokens, got %d", n, len(tokens))
+		}
+		return tokens, nil
+	}
+
+	tokens := make([]string, n)
+	for i := range tokens {
+		b := make([]byte, apiTokenSize)
+		if seed := env.seedFor("api_token", i); seed != "" {
+			s, err := hex.DecodeString(seed)
+			if err != nil {
+				return nil, err
+			}
+			copy(b, s)
+		} else if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("%s: %w", "api token rand.Read", err)
+		}
+		tokens[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return tokens, nil
+}
+
+// apiTokens returns the API-Token check with the exempt routes,
+// nil when there are no tokens.
+func (env *Env) apiTokens(exempt []string) *webproxy.ApiTokens {
+	if len(env.ApiTokens) == 0 {
+		return nil
+	}
+	return webproxy.NewApiTokens(env.ApiTokens, exempt)
+}
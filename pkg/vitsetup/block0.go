@@ -0,0 +1,262 @@
+package vitsetup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/rinor/jorcli/jcli"
+	"github.com/rinor/jorcli/jnode"
+)
+
+// BuildBlock0 builds the genesis block config (leaders, committee, fees, voters, voteplans, extra funds)
+// and generates the genesis block files and hash.
+func (env *Env) BuildBlock0() error {
+	var (
+		err error
+		cfg = env.Config
+	)
+
+	block0cfg := jnode.NewBlock0Config()
+
+	block0Discrimination := "production"
+	if discrimination == "testing" {
+		block0Discrimination = "test"
+	}
+
+	// set/change config params
+	block0cfg.BlockchainConfiguration.Block0Date = env.GenesisTime.Unix()
+	block0cfg.BlockchainConfiguration.Block0Consensus = consensus
+	block0cfg.BlockchainConfiguration.Discrimination = block0Discrimination
+
+	block0cfg.BlockchainConfiguration.SlotDuration = uint8(env.SlotDuration.Seconds())
+	block0cfg.BlockchainConfiguration.SlotsPerEpoch = uint32(env.EpochDuration / env.SlotDuration)
+
+	block0cfg.BlockchainConfiguration.LinearFees.Certificate = cfg.Fees.Certificate
+	block0cfg.BlockchainConfiguration.LinearFees.Coefficient = cfg.Fees.Coefficient
+	block0cfg.BlockchainConfiguration.LinearFees.Constant = cfg.Fees.Constant
+
+	block0cfg.BlockchainConfiguration.LinearFees.PerCertificateFees.CertificatePoolRegistration = cfg.Fees.CertificatePoolRegistration
+	block0cfg.BlockchainConfiguration.LinearFees.PerCertificateFees.CertificateStakeDelegation = cfg.Fees.CertificateStakeDelegation
+
+	block0cfg.BlockchainConfiguration.LinearFees.PerVoteCertificateFees.CertificateVoteCast = cfg.Fees.CertificateVoteCast
+	block0cfg.BlockchainConfiguration.LinearFees.PerVoteCertificateFees.CertificateVotePlan = cfg.Fees.CertificateVotePlan
+
+	block0cfg.BlockchainConfiguration.FeesGoTo = cfg.Fees.GoTo
+
+	// Bft Leader
+	for i := range env.Leaders {
+		if err = block0cfg.AddConsensusLeader(env.Leaders[i].PK); err != nil {
+			return err
+		}
+
+		// add bft leader(s) accounts to block0 (with bftLeaderFund value > 0)
+		if cfg.Leaders.Fund > 0 {
+			if err = block0cfg.AddInitialFund(env.Leaders[i].Account, cfg.Leaders.Fund); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Global Committee Members list
+	committeeAuthPublicKeys := cfg.Committee.AuthPublicKeys
+	if len(committeeAuthPublicKeys) > 0 {
+		committeePubAuth := make(map[string]bool, len(committeeAuthPublicKeys))
+		for i := range committeeAuthPublicKeys {
+			// Check if committee pk is on bft leaders
+			if env.leadersPubKey[committeeAuthPublicKeys[i]] {
+				log.Printf("***** Duplicate Committee member on BFT Leader, skip: %s *****", committeeAuthPublicKeys[i])
+				log.Println()
+				continue
+			}
+
+			if committeePubAuth[committeeAuthPublicKeys[i]] {
+				log.Printf("***** Duplicate Committee member, skip: %s *****", committeeAuthPublicKeys[i])
+				log.Println()
+				continue
+			}
+			committeePubAuth[committeeAuthPublicKeys[i]] = true
+
+			pk, err := jcli.KeyToBytes([]byte(committeeAuthPublicKeys[i]), "", "")
+			if err != nil {
+				return cmdErr(err, "jcli.KeyToBytes", pk)
+			}
+			if err = block0cfg.AddCommittee(kit.B2S(pk)); err != nil {
+				return err
+			}
+
+			// add committee accounts to block0 (with committeeFund value > 0)
+			if cfg.Committee.AuthFund > 0 {
+				comACC, err := jcli.AddressAccount(committeeAuthPublicKeys[i], "", "")
+				if err != nil {
+					return cmdErr(err, "jcli.AddressAccount", comACC)
+				}
+				if err = block0cfg.AddInitialFund(kit.B2S(comACC), cfg.Committee.AuthFund); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Generated voters wallets
+	for i := range env.Voters {
+		if err = block0cfg.AddInitialFund(env.Voters[i].Address, env.Voters[i].Value); err != nil {
+			return err
+		}
+	}
+
+	// Vote Plans add certificate to block0
+	if cfg.Vote.Block0VotePlan {
+		for i := range env.VotePlans {
+			if err = block0cfg.AddInitialCertificate(env.VotePlans[i].Certificate); err != nil {
+				return fmt.Errorf("%s: %w", "AddInitialCertificate", err)
+			}
+		}
+	}
+
+	block0Yaml, err := block0cfg.ToYaml()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Assets.GenesisExtraData != "" {
+		bulkExtraData, err := ioutil.ReadFile(cfg.Assets.GenesisExtraData)
+		if err != nil {
+			return err
+		}
+
+		if len(bulkExtraData) > 0 {
+			if len(block0cfg.Initial) == 0 {
+				block0Yaml = append(block0Yaml, "\ninitial:\n"...)
+			}
+			block0Yaml = append(block0Yaml, bulkExtraData...)
+		}
+	}
+
+	// need this file for starting the node (--genesis-block)
+	env.Block0BinFile = filepath.Join(env.WorkingDir, "VIT-block0.bin")
+
+	// keep also the text block0 config
+	env.Block0TxtFile = filepath.Join(env.WorkingDir, "VIT-block0.yaml")
+
+	// block0BinFile will be created by jcli
+	block0Bin, err := jcli.GenesisEncode(block0Yaml, "", env.Block0BinFile)
+	if err != nil {
+		return cmdErr(err, "jcli.GenesisEncode", append(block0Bin, block0Yaml...))
+	}
+
+	block0Hash, err := jcli.GenesisHash(block0Bin, "")
+	if err != nil {
+		return cmdErr(err, "jcli.GenesisHash", block0Hash)
+	}
+
+	// block0TxtFile will be created by jcli
+	block0Txt, err := jcli.GenesisDecode(block0Bin, "", env.Block0TxtFile)
+	if err != nil {
+		return cmdErr(err, "jcli.GenesisDecode", block0Txt)
+	}
+
+	env.Block0Cfg = block0cfg
+	env.Block0Bin = block0Bin
+	env.Block0Hash = kit.B2S(block0Hash)
+
+	return nil
+}
+
+// WriteNodeConfig writes the BFT leaders secret configs and the node config,
+// and prepares the node to be started.
+func (env *Env) WriteNodeConfig() error {
+	cfg := env.Config
+
+	//////////////////////
+	//  secrets config  //
+	//////////////////////
+
+	for i := range env.Leaders {
+		// we need secret key, but only public ones may have been provided
+		if env.Leaders[i].SK == "" {
+			continue
+		}
+
+		secretCfg := jnode.NewSecretConfig()
+
+		secretCfg.Bft.SigningKey = env.Leaders[i].SK
+
+		secretCfgYaml, err := secretCfg.ToYaml()
+		if err != nil {
+			return err
+		}
+
+		// need this file for starting the node (--secret)
+		secretCfgFile := env.Leaders[i].SKFile + ".yaml"
+		if err = ioutil.WriteFile(secretCfgFile, secretCfgYaml, 0744); err != nil {
+			return err
+		}
+
+		env.Leaders[i].CfgFile = secretCfgFile
+	}
+
+	///////////////////
+	//  node config  //
+	///////////////////
+
+	nodeCfg := jnode.NewNodeConfig()
+
+	nodeCfg.Storage = filepath.Join(env.WorkingDir, "storage")
+
+	nodeCfg.SkipBootstrap = cfg.Node.SkipBootstrap
+	nodeCfg.BootstrapFromTrustedPeers = true
+
+	nodeCfg.Rest.Listen = cfg.Node.Rest
+	nodeCfg.Rest.Cors.AllowedOrigins = strings.Split(cfg.Node.Cors, ",")
+	nodeCfg.Rest.Cors.MaxAgeSecs = 0
+
+	nodeCfg.P2P.PublicAddress = env.P2PListenAddress
+	nodeCfg.P2P.ListenAddress = env.P2PListenAddress
+	nodeCfg.P2P.AllowPrivateAddresses = true
+	nodeCfg.P2P.MaxBootstrapAttempts = 5
+
+	nodeCfg.Log.Level = cfg.Node.LogLevel
+
+	nodeCfg.Explorer.Enabled = cfg.Node.Explorer
+
+	for i := range env.Leaders {
+		// we need secret key to build config file, but only public ones may have been provided
+		if env.Leaders[i].CfgFile == "" {
+			continue
+		}
+		nodeCfg.AddSecretFile(env.Leaders[i].CfgFile)
+	}
+
+	nodeCfgYaml, err := nodeCfg.ToYaml()
+	if err != nil {
+		return err
+	}
+
+	// need this file for starting the node (--config)
+	env.NodeCfgFile = filepath.Join(env.WorkingDir, "node-config.yaml")
+	if err = ioutil.WriteFile(env.NodeCfgFile, nodeCfgYaml, 0644); err != nil {
+		return err
+	}
+
+	node := jnode.NewJnode()
+
+	node.WorkingDir = env.WorkingDir
+	node.GenesisBlock = env.Block0BinFile
+	node.ConfigFile = env.NodeCfgFile
+
+	for i := range env.Leaders {
+		// we need secret key to build config file, but only public ones may have been provided so no leader config possible
+		if env.Leaders[i].CfgFile == "" {
+			continue
+		}
+		node.AddSecretFile(env.Leaders[i].CfgFile)
+	}
+
+	env.Node = node
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package vitsetup
+
+import (
+	"strconv"
+	"time"
+)
+
+// ChainTime is the blockchain time representation (epoch.slot_id).
+type ChainTime struct {
+	Epoch  int64 `json:"epoch"`
+	SlotID int64 `json:"slot_id"`
+}
+
+func (ct ChainTime) String() string {
+	return strconv.FormatInt(ct.Epoch, 10) + "." + strconv.FormatInt(ct.SlotID, 10)
+}
+
+// ToChainTime converts dataTime (unix) to ChainTime based on block0 time and slots settings.
+func ToChainTime(block0Time int64, SlotDuration uint8, SlotsPerEpoch uint32, dataTime int64) ChainTime {
+	slotsTotal := (dataTime - block0Time) / int64(SlotDuration)
+	epoch := slotsTotal / int64(SlotsPerEpoch)
+	slot := slotsTotal % int64(SlotsPerEpoch)
+
+	return ChainTime{
+		Epoch:  epoch,
+		SlotID: slot,
+	}
+}
+
+// ChainTime converts t to ChainTime based on the environment genesis settings.
+func (env *Env) ChainTime(t time.Time) ChainTime {
+	return ToChainTime(
+		env.GenesisTime.Unix(),
+		uint8(env.SlotDuration.Seconds()),
+		uint32(env.EpochDuration/env.SlotDuration),
+		t.Unix(),
+	)
+}
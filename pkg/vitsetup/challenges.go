@@ -0,0 +1,37 @@
+package vitsetup
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// linkProposalsChallenges assigns the challenges without fund_id to the first fund
+// and checks that every proposal challenge exists and belongs to the proposal fund.
+func (env *Env) linkProposalsChallenges() error {
+	first := env.Funds.First()
+
+	for _, c := range *env.Challenges.All() {
+		if c.FundID == 0 {
+			c.FundID = first.FundID
+			continue
+		}
+		if env.Funds.SearchID(strconv.FormatUint(c.FundID, 10)) == nil {
+			return fmt.Errorf("challenge [%d] - %s [%d] not found", c.ID, "fund_id", c.FundID)
+		}
+	}
+
+	for _, p := range *env.Proposals.All() {
+		challenge := env.Challenges.SearchID(strconv.FormatUint(uint64(p.ChallengeID), 10))
+		switch {
+		case challenge == nil:
+			return fmt.Errorf("proposal [%d] - %s [%d] not found", p.InternalID, "challenge_id", p.ChallengeID)
+		case challenge.FundID != p.ChainVotePlan.FundID:
+			return fmt.Errorf(
+				"proposal [%d] - %s [%d] belongs to fund [%d], but proposal to fund [%d]",
+				p.InternalID, "challenge_id", p.ChallengeID, challenge.FundID, p.ChainVotePlan.FundID,
+			)
+		}
+	}
+
+	return nil
+}
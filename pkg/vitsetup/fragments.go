@@ -0,0 +1,218 @@
+package vitsetup
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/rinor/jorcli/jcli"
+)
+
+// FragmentPollInterval is the submitted fragments status polling interval.
+var FragmentPollInterval = 2 * time.Second
+
+// certTxFee returns the fee of a certificate transaction (1 account input, 0 outputs, 1 certificate).
+func (env *Env) certTxFee(certFee uint64) uint64 {
+	fees := env.Config.Fees
+	return fees.Constant + fees.Coefficient + certFee
+}
+
+// checkCertTx checks that the certificate transactions can be signed and their fees paid.
+func (env *Env) checkCertTx(certFee uint64) error {
+	if len(env.signerFiles()) == 0 {
+		return fmt.Errorf("no [%s] available to sign the transactions", "bft leader SK (secret key)")
+	}
+	if fee := env.certTxFee(certFee); fee > 0 && env.Config.Leaders.Fund < fee {
+		return fmt.Errorf("transaction fees are [%d], but the bft leader account fund is [%d] (bft-leader-fund)", fee, env.Config.Leaders.Fund)
+	}
+	return nil
+}
+
+// submitCertificate builds the transaction carrying cert, signed and authenticated
+// by the first BFT leader with a SK, posts it to the node and waits for it to be in a block.
+// With no fees the transaction has no inputs, so the leader account is not needed.
+// The transaction files are written next to file (staging, witness and message),
+// label prefixes the logged fragment ID.
+func (env *Env) submitCertificate(cert string, certFee uint64, file string, label string, deadline time.Time, stop <-chan struct{}) (string, error) {
+	var (
+		err     error
+		out     []byte
+		counter uint32
+
+		leader Leader
+
+		fees    = env.Config.Fees
+		fee     = env.certTxFee(certFee)
+		name    = filepath.Base(file)
+		staging = file + ".staging"
+		witness = file + ".witness"
+		message = file + ".msg"
+	)
+	for i := range env.Leaders {
+		if env.Leaders[i].SKFile != "" {
+			leader = env.Leaders[i]
+			break
+		}
+	}
+
+	if fee > 0 {
+		if counter, err = env.accountCounter(leader.Account); err != nil {
+			return "", err
+		}
+	}
+
+	if out, err = jcli.TransactionNew(nil, staging); err != nil {
+		return "", cmdErr(err, "jcli.TransactionNew", out)
+	}
+	if fee > 0 {
+		if out, err = jcli.TransactionAddAccount(nil, staging, leader.Account, fee); err != nil {
+			return "", cmdErr(err, "jcli.TransactionAddAccount", out)
+		}
+	}
+	if out, err = jcli.TransactionAddCertificate(nil, staging, cert); err != nil {
+		return "", cmdErr(err, "jcli.TransactionAddCertificate", out)
+	}
+	out, err = jcli.TransactionFinalize(nil, staging,
+		fees.Certificate, fees.Coefficient, fees.Constant,
+		fees.CertificatePoolRegistration, fees.CertificateStakeDelegation, 0,
+		fees.CertificateVoteCast, fees.CertificateVotePlan,
+		"",
+	)
+	if err != nil {
+		return "", cmdErr(err, "jcli.TransactionFinalize", out)
+	}
+	if fee > 0 {
+		dataForWitness, err := jcli.TransactionDataForWitness(nil, staging)
+		if err != nil {
+			return "", cmdErr(err, "jcli.TransactionDataForWitness", dataForWitness)
+		}
+		if out, err = jcli.TransactionMakeWitness(nil, kit.B2S(dataForWitness), env.Block0Hash, "account", counter, witness, leader.SKFile); err != nil {
+			return "", cmdErr(err, "jcli.TransactionMakeWitness", out)
+		}
+		if out, err = jcli.TransactionAddWitness(nil, staging, witness); err != nil {
+			return "", cmdErr(err, "jcli.TransactionAddWitness", out)
+		}
+	}
+	if out, err = jcli.TransactionSeal(nil, staging); err != nil {
+		return "", cmdErr(err, "jcli.TransactionSeal", out)
+	}
+	if out, err = jcli.TransactionAuth(nil, staging, []string{leader.SKFile}); err != nil {
+		return "", cmdErr(err, "jcli.TransactionAuth", out)
+	}
+	if out, err = jcli.TransactionToMessageFile(nil, staging, message); err != nil {
+		return "", cmdErr(err, "jcli.TransactionToMessageFile", out)
+	}
+
+	out, err = jcli.RestMessagePost(nil, env.nodeRestAPI(), message)
+	if err != nil {
+		return "", cmdErr(err, "jcli.RestMessagePost", out)
+	}
+	fragmentID := kit.B2S(out)
+	log.Printf("VIT - %s: %s - fragment %s submitted", label, name, fragmentID)
+
+	if err = env.waitFragment(fragmentID, deadline, stop); err != nil {
+		return fragmentID, fmt.Errorf("fragment %s: %w", fragmentID, err)
+	}
+	log.Printf("VIT - %s: %s - fragment %s in a block", label, name, fragmentID)
+
+	return fragmentID, nil
+}
+
+// accountCounter returns the account spending counter.
+func (env *Env) accountCounter(account string) (uint32, error) {
+	return accountCounter(env.nodeRestAPI(), account)
+}
+
+// accountCounter returns the account spending counter, read from the node REST api at nodeAPI.
+func accountCounter(nodeAPI string, account string) (uint32, error) {
+	out, err := jcli.RestAccount(account, nodeAPI, "json")
+	if err != nil {
+		return 0, cmdErr(err, "jcli.RestAccount", out)
+	}
+	var state struct {
+		Counter uint32 `json:"counter"`
+	}
+	if err = json.Unmarshal(out, &state); err != nil {
+		return 0, fmt.Errorf("%s: %w", "account state", err)
+	}
+	return state.Counter, nil
+}
+
+// fragmentStatus is the fragment status reported by the node fragment logs,
+// pending when not in a block and not rejected.
+type fragmentStatus struct {
+	InABlock bool
+	Rejected string // reason, empty if not rejected
+}
+
+// fragmentLogs returns the status of the fragments known by the node REST api at nodeAPI.
+func fragmentLogs(nodeAPI string) (map[string]fragmentStatus, error) {
+	out, err := jcli.RestMessageLogs(nodeAPI, "json")
+	if err != nil {
+		return nil, cmdErr(err, "jcli.RestMessageLogs", out)
+	}
+	var logs []struct {
+		FragmentID string          `json:"fragment_id"`
+		Status     json.RawMessage `json:"status"`
+	}
+	if err = json.Unmarshal(out, &logs); err != nil {
+		return nil, fmt.Errorf("%s: %w", "fragment logs", err)
+	}
+
+	statuses := make(map[string]fragmentStatus, len(logs))
+	for _, l := range logs {
+		// "Pending" or {"Rejected": {"reason": ...}} or {"InABlock": {...}}
+		var status map[string]json.RawMessage
+		if json.Unmarshal(l.Status, &status) != nil {
+			statuses[l.FragmentID] = fragmentStatus{} // pending
+			continue
+		}
+		_, inABlock := status["InABlock"]
+		fs := fragmentStatus{InABlock: inABlock}
+		if reason, ok := status["Rejected"]; ok {
+			fs.Rejected = strings.TrimSpace(string(reason))
+		}
+		statuses[l.FragmentID] = fs
+	}
+	return statuses, nil
+}
+
+// waitFragment polls the node fragment logs until fragmentID is in a block,
+// failing if rejected, or still pending at deadline.
+func (env *Env) waitFragment(fragmentID string, deadline time.Time, stop <-chan struct{}) error {
+	ticker := time.NewTicker(FragmentPollInterval)
+	defer ticker.Stop()
+
+	for {
+		statuses, err := fragmentLogs(env.nodeRestAPI())
+		if err != nil {
+			return err
+		}
+		if status, ok := statuses[fragmentID]; ok {
+			if status.InABlock {
+				return nil
+			}
+			if status.Rejected != "" {
+				return fmt.Errorf("rejected - %s", status.Rejected)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("still pending at %s", deadline.Format(env.Config.TimeFormat))
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return fmt.Errorf("%s", "stopped")
+		}
+	}
+}
+
+// nodeRestAPI returns the node REST api address used by jcli.
+func (env *Env) nodeRestAPI() string {
+	return "http://" + localAddress(env.Config.Node.Rest) + "/api"
+}
@@ -0,0 +1,125 @@
+package vitsetup
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// FundTiming contains the resolved voting timing of a fund voteplans.
+type FundTiming struct {
+	FundID uint64
+
+	VoteStartTime    time.Time
+	VoteEndTime      time.Time
+	CommitteeEndTime time.Time
+
+	VoteStart    ChainTime
+	VoteEnd      ChainTime
+	CommitteeEnd ChainTime
+}
+
+// linkProposalsFunds assigns the proposals without fund_id to the first fund
+// and checks that the referenced funds exist.
+func (env *Env) linkProposalsFunds() error {
+	first := env.Funds.First()
+	if first == nil {
+		return fmt.Errorf("%s - no fund found", "fund file")
+	}
+
+	for _, p := range *env.Proposals.All() {
+		if p.ChainVotePlan.FundID == 0 {
+			p.ChainVotePlan.FundID = first.FundID
+			continue
+		}
+		if env.Funds.SearchID(strconv.FormatUint(p.ChainVotePlan.FundID, 10)) == nil {
+			return fmt.Errorf("proposal [%d] - %s [%d] not found", p.InternalID, "fund_id", p.ChainVotePlan.FundID)
+		}
+	}
+
+	return nil
+}
+
+// fundsTimingByStart returns the resolved funds timing, ordered by vote start.
+func (env *Env) fundsTimingByStart() []FundTiming {
+	timings := make([]FundTiming, 0, len(env.FundsTiming))
+	for _, ft := range env.FundsTiming {
+		timings = append(timings, ft)
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if !timings[i].VoteStartTime.Equal(timings[j].VoteStartTime) {
+			return timings[i].VoteStartTime.Before(timings[j].VoteStartTime)
+		}
+		return timings[i].FundID < timings[j].FundID
+	})
+	return timings
+}
+
+// ResolveFundsTiming resolves the voting timing of each fund.
+// The first fund defaults to the config vote timing, while the next ones
+// start, when not provided, at the committee end of the previous fund.
+// Missing end times are calculated using the config vote and committee durations.
+// Missing fund values are updated with the resolved ones.
+func (env *Env) ResolveFundsTiming() error {
+	var (
+		err  error
+		prev FundTiming
+	)
+
+	env.FundsTiming = make(map[uint64]FundTiming, env.Funds.Total())
+
+	for i, fund := range *env.Funds.All() {
+		var (
+			ft   = FundTiming{FundID: fund.FundID}
+			name = "fund[" + strconv.FormatUint(fund.FundID, 10) + "]"
+
+			useConfig = i == 0 && fund.VoteStart == ""
+		)
+
+		switch {
+		case fund.VoteStart != "":
+		case i == 0:
+			fund.VoteStart = env.VoteStartTime.Format(time.RFC3339)
+		default:
+			fund.VoteStart = prev.CommitteeEndTime.Format(time.RFC3339)
+		}
+		ft.VoteStartTime, err = parseSlotsTime(name+" voteStart", fund.VoteStart, "genesisTime", env.GenesisTime, env.GenesisTime, env.SlotDuration)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case fund.VoteEnd != "":
+		case useConfig:
+			fund.VoteEnd = env.VoteEndTime.Format(time.RFC3339)
+		default:
+			fund.VoteEnd = ft.VoteStartTime.Add(env.VoteDuration).Format(time.RFC3339)
+		}
+		ft.VoteEndTime, err = parseSlotsTime(name+" voteEnd", fund.VoteEnd, "voteStart", ft.VoteStartTime, env.GenesisTime, env.SlotDuration)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case fund.CommitteeEnd != "":
+		case useConfig && fund.VoteEnd == env.VoteEndTime.Format(time.RFC3339):
+			fund.CommitteeEnd = env.CommitteeEndTime.Format(time.RFC3339)
+		default:
+			fund.CommitteeEnd = ft.VoteEndTime.Add(env.CommitteeDuration).Format(time.RFC3339)
+		}
+		ft.CommitteeEndTime, err = parseSlotsTime(name+" committeeEnd", fund.CommitteeEnd, "voteEnd", ft.VoteEndTime, env.GenesisTime, env.SlotDuration)
+		if err != nil {
+			return err
+		}
+
+		ft.VoteStart = env.ChainTime(ft.VoteStartTime)
+		ft.VoteEnd = env.ChainTime(ft.VoteEndTime)
+		ft.CommitteeEnd = env.ChainTime(ft.CommitteeEndTime)
+
+		env.FundsTiming[fund.FundID] = ft
+		prev = ft
+	}
+
+	return nil
+}
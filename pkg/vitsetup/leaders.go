@@ -0,0 +1,121 @@
+package vitsetup
+
+import (
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/rinor/jorcli/jcli"
+)
+
+// Leader contains the BFT leader keys and related files.
+type Leader struct {
+	SK      string // empty when only the PK was provided
+	PK      string
+	Account string
+	SKFile  string // needed later on to sign
+	CfgFile string // node secret config (--secret)
+}
+
+// BuildLeaders loads the provided BFT leaders keys and generates
+// new key pairs until the configured minimum is reached.
+func (env *Env) BuildLeaders() error {
+	var (
+		err error
+
+		bftLeaderTot         = env.Config.Leaders.Min
+		bftLeadersSecretKeys = env.Config.Leaders.SecretKeys
+		bftLeadersPublicKeys = env.Config.Leaders.PublicKeys
+
+		bftFileIdx int
+		bftPkIdx   int
+		bftGenIdx  int // generated keys, so a duplicate won't get the same seed again
+	)
+
+	env.Leaders = make([]Leader, 0, bftLeaderTot)
+	env.leadersPubKey = make(map[string]bool, bftLeaderTot)
+
+	for i := 0; uint(i) < bftLeaderTot; i++ {
+		var (
+			leaderSK      []byte
+			leaderPK      []byte
+			bftSecretFile string
+		)
+
+		switch {
+
+		case len(bftLeadersSecretKeys)-bftFileIdx > 0:
+			leaderSK, err = ioutil.ReadFile(bftLeadersSecretKeys[bftFileIdx])
+			if err != nil {
+				return err
+			}
+			leaderPK, err = jcli.KeyToPublic(leaderSK, "", "")
+			if err != nil {
+				return cmdErr(err, "jcli.KeyToPublic", leaderPK)
+			}
+			bftFileIdx++
+
+		case len(bftLeadersPublicKeys)-bftPkIdx > 0:
+			leaderPK = []byte(bftLeadersPublicKeys[bftPkIdx])
+			bftPkIdx++
+
+		default:
+			leaderSK, err = jcli.KeyGenerate(env.seedFor("bft_leader", bftGenIdx), "Ed25519", "")
+			if err != nil {
+				return cmdErr(err, "jcli.KeyGenerate", leaderSK)
+			}
+			bftGenIdx++
+			leaderPK, err = jcli.KeyToPublic(leaderSK, "", "")
+			if err != nil {
+				return cmdErr(err, "jcli.KeyToPublic", leaderPK)
+			}
+		}
+
+		if env.leadersPubKey[kit.B2S(leaderPK)] {
+			i-- // needed to reach bftLeaderTot, won't go below 0
+			log.Printf("***** Duplicate BFT Leader skip: %s *****", kit.B2S(leaderPK))
+			log.Println()
+			continue
+		}
+		env.leadersPubKey[kit.B2S(leaderPK)] = true
+
+		leaderACC, err := jcli.AddressAccount(kit.B2S(leaderPK), "", "")
+		if err != nil {
+			return cmdErr(err, "jcli.AddressAccount", leaderACC)
+		}
+
+		if len(leaderSK) > 0 {
+			// Needed later on to sign
+			bftSecretFile = filepath.Join(env.WorkingDir, strconv.Itoa(i)+"_bft_secret.key")
+			err = ioutil.WriteFile(bftSecretFile, leaderSK, 0744)
+			if err != nil {
+				return err
+			}
+		}
+
+		env.Leaders = append(env.Leaders, Leader{
+			SK:      kit.B2S(leaderSK),
+			PK:      kit.B2S(leaderPK),
+			Account: kit.B2S(leaderACC),
+			SKFile:  bftSecretFile,
+		})
+	}
+
+	return nil
+}
+
+// signerFiles returns the BFT leaders SK files that can be used to sign certificates.
+func (env *Env) signerFiles() []string {
+	certSignersFiles := make([]string, 0) //, 0, len(leaders))
+	for i := range env.Leaders {
+		// we need a secret key
+		if env.Leaders[i].SKFile == "" {
+			continue
+		}
+		certSignersFiles = append(certSignersFiles, env.Leaders[i].SKFile)
+		break // right now only one key is needed to sign a certificate so bail as soon as we have one
+	}
+	return certSignersFiles
+}
@@ -0,0 +1,558 @@
+package vitsetup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/input-output-hk/jorvit/internal/config"
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/input-output-hk/jorvit/internal/loader"
+	"github.com/input-output-hk/jorvit/internal/webproxy"
+	"github.com/rinor/jorcli/jcli"
+)
+
+// LoadGen casts votes with the generated voter wallets on the proposals voteplans,
+// submitting the vote cast fragments to the node at a given rate and concurrency.
+// Each voter votes once on each proposal, with a random choice.
+type LoadGen struct {
+	NodeAPI        string // node REST api, ex: http://127.0.0.1:8001/api
+	Block0Hash     string
+	Fees           config.Fees
+	Voters         []Voter // with SKFile
+	Proposals      []loader.ProposalData
+	VoteEncKeyFile string // private voteplans only
+
+	Dir         string        // fragments files
+	Rate        float64       // fragments per second, 0 means no limit
+	Concurrency int           // parallel submissions, each one with its own voters
+	Votes       int           // max number of votes, 0 means every voter on every proposal
+	Wait        time.Duration // time the pending fragments have to be in a block, after the last submission
+}
+
+// LoadGenReport is the LoadGen outcome.
+type LoadGenReport struct {
+	Votes    int           `json:"votes"`    // attempted
+	Accepted int           `json:"accepted"` // by the node REST api
+	InBlock  int           `json:"in_block"`
+	Rejected int           `json:"rejected"` // by the node REST api or the fragment logs
+	Pending  int           `json:"pending"`  // accepted, but not in a block within the wait
+	Failed   int           `json:"failed"`   // not built or not sent
+	Elapsed  time.Duration `json:"elapsed"`
+
+	SubmitLatency  Latency `json:"submit_latency"`   // fragment post
+	InBlockLatency Latency `json:"in_block_latency"` // from the post to the block, fragment logs polling resolution
+}
+
+// Latency percentiles.
+type Latency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// voteTarget is a voteplan proposal to vote on.
+type voteTarget struct {
+	VotePlanID string
+	Payload    string
+	Index      uint8
+	Options    uint8
+}
+
+// loadGenVoter is a voter with its tracked spending counter.
+type loadGenVoter struct {
+	Voter
+	counter uint32
+	synced  int32 // 1 when counter was read from the node, cleared on rejections (atomic, also by the fragment tracker)
+	pending int32 // fragments submitted, not yet in a block or rejected (atomic)
+}
+
+// NodeSettings returns the block0 hash and the fees of the node REST api at nodeAPI.
+func NodeSettings(nodeAPI string) (string, config.Fees, error) {
+	var fees config.Fees
+
+	out, err := jcli.RestSettings(nodeAPI, "json")
+	if err != nil {
+		return "", fees, cmdErr(err, "jcli.RestSettings", out)
+	}
+	var settings struct {
+		Block0Hash string `json:"block0Hash"`
+		Fees       struct {
+			Certificate        uint64 `json:"certificate"`
+			Coefficient        uint64 `json:"coefficient"`
+			Constant           uint64 `json:"constant"`
+			PerCertificateFees struct {
+				CertificatePoolRegistration uint64 `json:"certificate_pool_registration"`
+				CertificateStakeDelegation  uint64 `json:"certificate_stake_delegation"`
+			} `json:"per_certificate_fees"`
+			PerVoteCertificateFees struct {
+				CertificateVotePlan uint64 `json:"certificate_vote_plan"`
+				CertificateVoteCast uint64 `json:"certificate_vote_cast"`
+			} `json:"per_vote_certificate_fees"`
+		} `json:"fees"`
+	}
+	if err = json.Unmarshal(out, &settings); err != nil {
+		return "", fees, fmt.Errorf("%s: %w", "node settings", err)
+	}
+
+	fees.Certificate = settings.Fees.Certificate
+	fees.Coefficient = settings.Fees.Coefficient
+	fees.Constant = settings.Fees.Constant
+	fees.CertificatePoolRegistration = settings.Fees.PerCertificateFees.CertificatePoolRegistration
+	fees.CertificateStakeDelegation = settings.Fees.PerCertificateFees.CertificateStakeDelegation
+	fees.CertificateVotePlan = settings.Fees.PerVoteCertificateFees.CertificateVotePlan
+	fees.CertificateVoteCast = settings.Fees.PerVoteCertificateFees.CertificateVoteCast
+
+	return settings.Block0Hash, fees, nil
+}
+
+// FetchProposals returns the proposals served by the jorvit proxy at proxyURL (ex: http://127.0.0.1:8000).
+func FetchProposals(ctx context.Context, client *http.Client, proxyURL string, apiToken string) ([]loader.ProposalData, error) {
+	req, err := http.NewRequest("GET", strings.TrimRight(proxyURL, "/")+"/api/v0/proposals", nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if apiToken != "" {
+		req.Header.Set(webproxy.ApiTokenHeader, apiToken)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proposals: %s", res.Status)
+	}
+	proposals := make([]loader.ProposalData, 0)
+	if err = json.NewDecoder(res.Body).Decode(&proposals); err != nil {
+		return nil, fmt.Errorf("proposals: %w", err)
+	}
+	return proposals, nil
+}
+
+// voteCastFee returns the fee of a vote cast transaction (1 account input, 0 outputs, 1 certificate).
+func (lg *LoadGen) voteCastFee() uint64 {
+	certFee := lg.Fees.Certificate
+	if lg.Fees.CertificateVoteCast > 0 {
+		certFee = lg.Fees.CertificateVoteCast
+	}
+	return lg.Fees.Constant + lg.Fees.Coefficient + certFee
+}
+
+// targets returns the voteplans proposals to vote on.
+func (lg *LoadGen) targets() ([]voteTarget, error) {
+	targets := make([]voteTarget, 0, len(lg.Proposals))
+	for _, p := range lg.Proposals {
+		if p.ChainVotePlan == nil || p.VotePlanID == "" {
+			continue
+		}
+		if p.Payload == "private" && lg.VoteEncKeyFile == "" {
+			return nil, fmt.Errorf("proposal [%d] - %s voteplan, but no vote encryption key provided", p.InternalID, p.Payload)
+		}
+		if len(p.VoteOptions) == 0 {
+			return nil, fmt.Errorf("proposal [%d] - %s", p.InternalID, "no vote options")
+		}
+		targets = append(targets, voteTarget{
+			VotePlanID: p.VotePlanID,
+			Payload:    p.Payload,
+			Index:      p.Index,
+			Options:    uint8(len(p.VoteOptions)),
+		})
+	}
+	return targets, nil
+}
+
+// Run casts the votes until done or ctx is done, then waits for the pending fragments.
+func (lg *LoadGen) Run(ctx context.Context) (*LoadGenReport, error) {
+	if len(lg.Voters) == 0 {
+		return nil, fmt.Errorf("no voters available")
+	}
+	targets, err := lg.targets()
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no proposals with a voteplan available")
+	}
+	if lg.Concurrency < 1 {
+		lg.Concurrency = 1
+	}
+	if lg.Concurrency > len(lg.Voters) {
+		lg.Concurrency = len(lg.Voters) // each voter is used by one worker only
+	}
+	if err = os.MkdirAll(lg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("%s: %w", "loadgen dir", err)
+	}
+
+	total := len(lg.Voters) * len(targets)
+	if lg.Votes > 0 && lg.Votes < total {
+		total = lg.Votes
+	}
+
+	var (
+		claimed int64
+		start   = time.Now()
+		tracker = newFragmentTracker(lg.NodeAPI)
+		report  = &LoadGenReport{}
+		mu      sync.Mutex // report
+		submitL []time.Duration
+		wg      sync.WaitGroup
+		tick    <-chan time.Time
+	)
+	if lg.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / lg.Rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	trackerDone := make(chan struct{})
+	go func() {
+		tracker.run(ctx)
+		close(trackerDone)
+	}()
+
+	log.Printf("VIT - LOADGEN: %d votes, %d voters, %d proposals, rate %v/s, concurrency %d, vote cast fee %d",
+		total, len(lg.Voters), len(targets), lg.Rate, lg.Concurrency, lg.voteCastFee())
+
+	for w := 0; w < lg.Concurrency; w++ {
+		voters := make([]*loadGenVoter, 0, len(lg.Voters)/lg.Concurrency+1)
+		for i := w; i < len(lg.Voters); i += lg.Concurrency {
+			voters = append(voters, &loadGenVoter{Voter: lg.Voters[i]})
+		}
+
+		wg.Add(1)
+		go func(w int, voters []*loadGenVoter) {
+			defer wg.Done()
+			rnd := mrand.New(mrand.NewSource(time.Now().UnixNano() + int64(w)))
+			file := filepath.Join(lg.Dir, strconv.Itoa(w)+"_vote")
+
+			for _, target := range targets {
+				for _, voter := range voters {
+					if atomic.AddInt64(&claimed, 1) > int64(total) {
+						return
+					}
+					if atomic.LoadInt32(&voter.synced) == 0 {
+						lg.waitVoterPending(ctx, voter)
+					}
+					if tick != nil {
+						select {
+						case <-tick:
+						case <-ctx.Done():
+							return
+						}
+					} else if ctx.Err() != nil {
+						return
+					}
+
+					choice := uint8(rnd.Intn(int(target.Options)))
+					fragmentID, latency, err := lg.castVote(voter, target, choice, file)
+
+					mu.Lock()
+					report.Votes++
+					switch {
+					case fragmentID != "":
+						report.Accepted++
+						submitL = append(submitL, latency)
+						tracker.add(fragmentID, voter, time.Now())
+					case err == errFragmentRejected:
+						report.Rejected++
+					default:
+						report.Failed++
+						log.Printf("***** VIT - LOADGEN: %s voter %s - %s", target.VotePlanID, voter.Address, err)
+					}
+					mu.Unlock()
+				}
+			}
+		}(w, voters)
+	}
+	wg.Wait()
+
+	log.Printf("VIT - LOADGEN: %d votes submitted in %s, waiting up to %s for the pending fragments", report.Accepted, time.Since(start).Round(time.Millisecond), lg.Wait)
+	tracker.waitPending(ctx, lg.Wait)
+	tracker.stop()
+	<-trackerDone
+
+	report.Elapsed = time.Since(start)
+	inBlock, rejected, pending, inBlockL := tracker.result()
+	report.InBlock, report.Rejected, report.Pending = inBlock, report.Rejected+rejected, pending
+	report.SubmitLatency = percentiles(submitL)
+	report.InBlockLatency = percentiles(inBlockL)
+
+	return report, nil
+}
+
+// waitVoterPending blocks until the voter has no pending fragments, for at most the wait time.
+// The node account counter (the ledger one) ignores the fragments still in the mempool,
+// and after a rejection the next ones of the voter are rejected as well, so the counter
+// is read again once they are settled.
+func (lg *LoadGen) waitVoterPending(ctx context.Context, voter *loadGenVoter) {
+	deadline := time.NewTimer(lg.Wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(FragmentPollInterval)
+	defer ticker.Stop()
+
+	for atomic.LoadInt32(&voter.pending) > 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// errFragmentRejected is returned when the node REST api refuses the fragment.
+var errFragmentRejected = fmt.Errorf("fragment rejected")
+
+// castVote builds the vote cast transaction of voter on target and posts it to the node,
+// returning the fragment ID and the post latency.
+// The voter spending counter is read from the node on first use, or after a rejection
+// (once its pending fragments are settled, see waitVoterPending).
+func (lg *LoadGen) castVote(voter *loadGenVoter, target voteTarget, choice uint8, file string) (string, time.Duration, error) {
+	var (
+		err  error
+		out  []byte
+		cert []byte
+
+		fee     = lg.voteCastFee()
+		fees    = lg.Fees
+		staging = file + ".staging"
+		witness = file + ".witness"
+		message = file + ".msg"
+	)
+
+	if atomic.LoadInt32(&voter.synced) == 0 {
+		if voter.counter, err = accountCounter(lg.NodeAPI, voter.Address); err != nil {
+			return "", 0, err
+		}
+		atomic.StoreInt32(&voter.synced, 1)
+	}
+
+	switch target.Payload {
+	case "public":
+		if cert, err = jcli.CertificateNewVoteCastPublic(target.VotePlanID, target.Index, choice, ""); err != nil {
+			return "", 0, cmdErr(err, "jcli.CertificateNewVoteCastPublic", cert)
+		}
+	case "private":
+		if cert, err = jcli.CertificateNewVoteCastPrivate(nil, target.VotePlanID, target.Index, choice, target.Options, lg.VoteEncKeyFile, ""); err != nil {
+			return "", 0, cmdErr(err, "jcli.CertificateNewVoteCastPrivate", cert)
+		}
+	default:
+		return "", 0, fmt.Errorf("unknown payload type [%s]", target.Payload)
+	}
+
+	for _, f := range []string{staging, witness, message} {
+		_ = os.Remove(f)
+	}
+	if out, err = jcli.TransactionNew(nil, staging); err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionNew", out)
+	}
+	// the vote cast needs the voter account input, even with no fees
+	if out, err = jcli.TransactionAddAccount(nil, staging, voter.Address, fee); err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionAddAccount", out)
+	}
+	if out, err = jcli.TransactionAddCertificate(nil, staging, kit.B2S(cert)); err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionAddCertificate", out)
+	}
+	out, err = jcli.TransactionFinalize(nil, staging,
+		fees.Certificate, fees.Coefficient, fees.Constant,
+		fees.CertificatePoolRegistration, fees.CertificateStakeDelegation, 0,
+		fees.CertificateVoteCast, fees.CertificateVotePlan,
+		"",
+	)
+	if err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionFinalize", out)
+	}
+	dataForWitness, err := jcli.TransactionDataForWitness(nil, staging)
+	if err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionDataForWitness", dataForWitness)
+	}
+	if out, err = jcli.TransactionMakeWitness(nil, kit.B2S(dataForWitness), lg.Block0Hash, "account", voter.counter, witness, voter.SKFile); err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionMakeWitness", out)
+	}
+	if out, err = jcli.TransactionAddWitness(nil, staging, witness); err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionAddWitness", out)
+	}
+	if out, err = jcli.TransactionSeal(nil, staging); err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionSeal", out)
+	}
+	if out, err = jcli.TransactionToMessageFile(nil, staging, message); err != nil {
+		return "", 0, cmdErr(err, "jcli.TransactionToMessageFile", out)
+	}
+
+	posted := time.Now()
+	out, err = jcli.RestMessagePost(nil, lg.NodeAPI, message)
+	latency := time.Since(posted)
+	if err != nil {
+		atomic.StoreInt32(&voter.synced, 0) // the counter could be out of sync
+		return "", latency, errFragmentRejected
+	}
+	voter.counter++
+
+	return kit.B2S(out), latency, nil
+}
+
+// fragmentTracker follows the submitted fragments status on the node fragment logs.
+// The voter of a fragment rejected by the node is marked out of sync,
+// since its spending counter was already incremented.
+type fragmentTracker struct {
+	nodeAPI string
+
+	mu       sync.Mutex
+	pending  map[string]pendingFragment // by fragment ID
+	inBlock  int
+	rejected int
+	latency  []time.Duration
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// pendingFragment is a submitted fragment not yet in a block or rejected.
+type pendingFragment struct {
+	voter     *loadGenVoter
+	submitted time.Time
+}
+
+func newFragmentTracker(nodeAPI string) *fragmentTracker {
+	return &fragmentTracker{
+		nodeAPI: nodeAPI,
+		pending: make(map[string]pendingFragment),
+		done:    make(chan struct{}),
+	}
+}
+
+func (t *fragmentTracker) add(fragmentID string, voter *loadGenVoter, submitted time.Time) {
+	t.mu.Lock()
+	t.pending[fragmentID] = pendingFragment{voter: voter, submitted: submitted}
+	atomic.AddInt32(&voter.pending, 1)
+	t.mu.Unlock()
+}
+
+// run polls the fragment logs until stop is called or ctx is done.
+func (t *fragmentTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(FragmentPollInterval)
+	defer ticker.Stop()
+
+	var lastErr string
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.done:
+			t.poll() // last status
+			return
+		case <-ctx.Done():
+			return
+		}
+		if err := t.poll(); err != nil && err.Error() != lastErr {
+			lastErr = err.Error()
+			log.Printf("***** VIT - LOADGEN: %s", err)
+		}
+	}
+}
+
+func (t *fragmentTracker) poll() error {
+	t.mu.Lock()
+	empty := len(t.pending) == 0
+	t.mu.Unlock()
+	if empty {
+		return nil
+	}
+
+	statuses, err := fragmentLogs(t.nodeAPI)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, fragment := range t.pending {
+		status, ok := statuses[id]
+		switch {
+		case !ok:
+		case status.InABlock:
+			t.inBlock++
+			t.latency = append(t.latency, now.Sub(fragment.submitted))
+			atomic.AddInt32(&fragment.voter.pending, -1)
+			delete(t.pending, id)
+		case status.Rejected != "":
+			t.rejected++
+			atomic.StoreInt32(&fragment.voter.synced, 0) // its counter was incremented
+			atomic.AddInt32(&fragment.voter.pending, -1)
+			delete(t.pending, id)
+		}
+	}
+	return nil
+}
+
+// waitPending blocks until there are no pending fragments, for at most timeout.
+func (t *fragmentTracker) waitPending(ctx context.Context, timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(FragmentPollInterval)
+	defer ticker.Stop()
+
+	for {
+		t.mu.Lock()
+		pending := len(t.pending)
+		t.mu.Unlock()
+		if pending == 0 {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t *fragmentTracker) stop() {
+	t.stopOnce.Do(func() { close(t.done) })
+}
+
+func (t *fragmentTracker) result() (inBlock int, rejected int, pending int, latency []time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.inBlock, t.rejected, len(t.pending), append([]time.Duration{}, t.latency...)
+}
+
+// percentiles returns the latency percentiles of ds.
+func percentiles(ds []time.Duration) Latency {
+	if len(ds) == 0 {
+		return Latency{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	at := func(p float64) time.Duration {
+		return ds[int(p*float64(len(ds)-1))]
+	}
+	return Latency{P50: at(0.50), P90: at(0.90), P99: at(0.99), Max: ds[len(ds)-1]}
+}
+
+// WriteReport writes the report as JSON to filename.
+func (r *LoadGenReport) WriteReport(filename string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
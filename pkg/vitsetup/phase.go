@@ -0,0 +1,330 @@
+package vitsetup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/input-output-hk/jorvit/internal/webproxy"
+)
+
+// Phase is the voting phase of the scenario timing.
+type Phase string
+
+// Voting phases, in chain order.
+const (
+	PhasePreVote  Phase = "pre-vote" // before vote start
+	PhaseVoting   Phase = "voting"   // from vote start to vote end
+	PhaseTally    Phase = "tally"    // from vote end to committee end
+	PhaseFinished Phase = "finished" // after committee end
+)
+
+// Phases are the voting phases, in chain order.
+var Phases = []Phase{PhasePreVote, PhaseVoting, PhaseTally, PhaseFinished}
+
+// ParsePhase returns the Phase named s.
+func ParsePhase(s string) (Phase, error) {
+	for _, p := range Phases {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown phase [%s], one of %v", s, Phases)
+}
+
+// Reached reports whether p is phase or a later one.
+func (p Phase) Reached(phase Phase) bool {
+	return p.order() >= phase.order()
+}
+
+func (p Phase) order() int {
+	for i := range Phases {
+		if Phases[i] == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// PhaseInfo is the voting phase at a given time, the one of the current fund
+// (the first one, by vote start, not finished yet) together with the phase of each fund.
+type PhaseInfo struct {
+	Phase         Phase       `json:"phase"`
+	FundID        uint64      `json:"fund_id"`
+	Time          time.Time   `json:"time"`
+	ChainTime     ChainTime   `json:"chain_time"`
+	Next          Phase       `json:"next_phase,omitempty"`
+	NextTime      *time.Time  `json:"next_time,omitempty"`
+	NextChainTime *ChainTime  `json:"next_chain_time,omitempty"`
+	Funds         []FundPhase `json:"funds"`
+}
+
+// FundPhase is the voting phase of a fund voteplans.
+type FundPhase struct {
+	FundID        uint64     `json:"fund_id"`
+	Phase         Phase      `json:"phase"`
+	Next          Phase      `json:"next_phase,omitempty"`
+	NextTime      *time.Time `json:"next_time,omitempty"`
+	NextChainTime *ChainTime `json:"next_chain_time,omitempty"`
+}
+
+// PhaseAt returns the voting phase at t, based on the resolved timing of each fund.
+func (env *Env) PhaseAt(t time.Time) PhaseInfo {
+	t = t.UTC()
+	info := PhaseInfo{
+		Time:      t,
+		ChainTime: env.ChainTime(t),
+	}
+
+	timings := env.fundsTimingByStart()
+	if len(timings) == 0 {
+		// funds timing not resolved yet
+		timings = []FundTiming{{
+			VoteStartTime: env.VoteStartTime, VoteEndTime: env.VoteEndTime, CommitteeEndTime: env.CommitteeEndTime,
+			VoteStart: env.VoteStart, VoteEnd: env.VoteEnd, CommitteeEnd: env.CommitteeEnd,
+		}}
+	}
+
+	info.Funds = make([]FundPhase, len(timings))
+	current := -1
+	for i, ft := range timings {
+		info.Funds[i] = fundPhaseAt(ft, t)
+		if current < 0 && info.Funds[i].Phase != PhaseFinished {
+			current = i
+		}
+	}
+	if current < 0 {
+		current = len(timings) - 1
+	}
+
+	fp := info.Funds[current]
+	info.Phase, info.FundID = fp.Phase, fp.FundID
+	info.Next, info.NextTime, info.NextChainTime = fp.Next, fp.NextTime, fp.NextChainTime
+
+	return info
+}
+
+// fundPhaseAt returns the voting phase of the fund with timing ft at t.
+func fundPhaseAt(ft FundTiming, t time.Time) FundPhase {
+	fp := FundPhase{FundID: ft.FundID}
+
+	var next time.Time
+	var nextChainTime ChainTime
+	switch {
+	case t.Before(ft.VoteStartTime):
+		fp.Phase, fp.Next, next, nextChainTime = PhasePreVote, PhaseVoting, ft.VoteStartTime, ft.VoteStart
+	case t.Before(ft.VoteEndTime):
+		fp.Phase, fp.Next, next, nextChainTime = PhaseVoting, PhaseTally, ft.VoteEndTime, ft.VoteEnd
+	case t.Before(ft.CommitteeEndTime):
+		fp.Phase, fp.Next, next, nextChainTime = PhaseTally, PhaseFinished, ft.CommitteeEndTime, ft.CommitteeEnd
+	default:
+		fp.Phase = PhaseFinished
+		return fp
+	}
+	fp.NextTime, fp.NextChainTime = &next, &nextChainTime
+
+	return fp
+}
+
+// nextTransition returns the time of the next phase transition of any fund, nil when all finished.
+func (info PhaseInfo) nextTransition() *time.Time {
+	var next *time.Time
+	for _, fp := range info.Funds {
+		if fp.NextTime != nil && (next == nil || fp.NextTime.Before(*next)) {
+			next = fp.NextTime
+		}
+	}
+	return next
+}
+
+// PhaseClock tracks the voting phase, logging and notifying the subscribers on each transition.
+type PhaseClock struct {
+	env *Env
+
+	mu   sync.Mutex
+	subs map[chan interface{}]bool
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewPhaseClock returns the env PhaseClock, not yet running.
+func NewPhaseClock(env *Env) *PhaseClock {
+	return &PhaseClock{
+		env:  env,
+		subs: make(map[chan interface{}]bool),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Run tracks the phase transitions until Stop is called.
+func (c *PhaseClock) Run() {
+	defer close(c.done)
+
+	info := c.env.PhaseAt(time.Now())
+	c.log(info)
+
+	for next := info.nextTransition(); next != nil; next = info.nextTransition() {
+		timer := time.NewTimer(time.Until(*next))
+		select {
+		case <-timer.C:
+		case <-c.stop:
+			timer.Stop()
+			return
+		}
+
+		info = c.env.PhaseAt(time.Now())
+		c.log(info)
+		c.notify(info)
+	}
+
+	<-c.stop
+}
+
+// Stop stops the clock and ends the subscriptions.
+func (c *PhaseClock) Stop() {
+	c.mu.Lock()
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+		for sub := range c.subs {
+			delete(c.subs, sub)
+			close(sub)
+		}
+	}
+	c.mu.Unlock()
+
+	<-c.done
+}
+
+// Current returns the current PhaseInfo.
+func (c *PhaseClock) Current() interface{} {
+	return c.env.PhaseAt(time.Now())
+}
+
+// Subscribe returns the channel receiving the PhaseInfo on each transition,
+// closed by cancel or when the clock stops.
+func (c *PhaseClock) Subscribe() (<-chan interface{}, func()) {
+	sub := make(chan interface{}, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	select {
+	case <-c.stop:
+		close(sub)
+		return sub, func() {}
+	default:
+	}
+	c.subs[sub] = true
+
+	cancel := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.subs[sub] {
+			delete(c.subs, sub)
+			close(sub)
+		}
+	}
+	return sub, cancel
+}
+
+// notify sends info to the subscribers, replacing the not yet received one.
+func (c *PhaseClock) notify(info PhaseInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for sub := range c.subs {
+		select {
+		case <-sub:
+		default:
+		}
+		sub <- info
+	}
+}
+
+func (c *PhaseClock) log(info PhaseInfo) {
+	if info.NextTime == nil {
+		log.Printf("VIT - PHASE: %s, fund [%d] (%s)", info.Phase, info.FundID, info.ChainTime)
+		return
+	}
+	log.Printf("VIT - PHASE: %s, fund [%d] (%s), %s at %s (%s)",
+		info.Phase, info.FundID, info.ChainTime, info.Next, info.NextTime.Format(c.env.Config.TimeFormat), info.NextChainTime,
+	)
+}
+
+var _ webproxy.PhaseSource = (*PhaseClock)(nil)
+
+// WaitForPhase blocks until the jorvit proxy at proxyURL (ex: http://127.0.0.1:8000)
+// reports phase, or a later one, following its phase events stream.
+// The proxy is reconnected every retry until ctx is done.
+func WaitForPhase(ctx context.Context, client *http.Client, proxyURL string, apiToken string, phase Phase, retry time.Duration) (PhaseInfo, error) {
+	var lastErr error
+
+	for {
+		info, err := followPhase(ctx, client, proxyURL, apiToken, phase)
+		if err == nil {
+			return info, nil
+		}
+		if lastErr == nil || err.Error() != lastErr.Error() {
+			log.Printf("***** wait-for %s: %s - retrying every %s", phase, err, retry)
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return PhaseInfo{}, fmt.Errorf("%s: %w (%v)", "wait-for "+phase, ctx.Err(), lastErr)
+		case <-time.After(retry):
+		}
+	}
+}
+
+// followPhase reads the phase events stream until phase is reached.
+func followPhase(ctx context.Context, client *http.Client, proxyURL string, apiToken string, phase Phase) (PhaseInfo, error) {
+	var info PhaseInfo
+
+	req, err := http.NewRequest("GET", strings.TrimRight(proxyURL, "/")+"/api/v0/jorvit/phase/events", nil)
+	if err != nil {
+		return info, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "text/event-stream")
+	if apiToken != "" {
+		req.Header.Set(webproxy.ApiTokenHeader, apiToken)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return info, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return info, fmt.Errorf("phase events: %s", res.Status)
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		if err = json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &info); err != nil {
+			return info, fmt.Errorf("phase events: %w", err)
+		}
+		if info.Phase.Reached(phase) {
+			return info, nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return info, err
+	}
+	return info, fmt.Errorf("phase events: %s", "stream closed")
+}
@@ -0,0 +1,69 @@
+package vitsetup
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// probeClient is used for the services readiness probes.
+// The services are local ones, so the (self signed) certificates are not verified.
+var probeClient = &http.Client{
+	Timeout: 2 * time.Second,
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+}
+
+// nodeReady probes the node REST api, the node is ready once its state is Running.
+func (env *Env) nodeReady() error {
+	res, err := probeClient.Get("http://" + localAddress(env.Config.Node.Rest) + "/api/v0/node/stats")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("node stats: %s", res.Status)
+	}
+	var stats struct {
+		State string `json:"state"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&stats); err != nil {
+		return fmt.Errorf("node stats: %w", err)
+	}
+	if stats.State != "Running" {
+		return fmt.Errorf("node state: %s", stats.State)
+	}
+	return nil
+}
+
+// stationReady probes the vit station, it is ready once answering HTTP requests.
+func (env *Env) stationReady() error {
+	res, err := probeClient.Get(env.Scheme() + "://" + localAddress(env.Config.Station.Listen) + "/api/v0/fund")
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("vit station: %s", res.Status)
+	}
+	return nil
+}
+
+// localAddress returns the address to reach a service listening on address,
+// the loopback one when listening on any.
+func localAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,57 @@
+package vitsetup
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/input-output-hk/jorvit/internal/config"
+	"golang.org/x/crypto/blake2b"
+)
+
+// seedFor returns the jcli seed (32 bytes hex) to be used for the generated key
+// identified by purpose and index, derived from the scenario seed.
+// Empty string (random key) is returned when not in reproducible mode.
+func (env *Env) seedFor(purpose string, index int) string {
+	if !env.Config.IsReproducible() {
+		return ""
+	}
+	return env.deriveSeed(purpose, index)
+}
+
+func (env *Env) deriveSeed(purpose string, index int) string {
+	seed := env.Config.Seed
+	if seed == "" {
+		seed = config.DefaultSeed
+	}
+	sum := blake2b.Sum256([]byte(seed + "/" + purpose + "/" + strconv.Itoa(index)))
+	return hex.EncodeToString(sum[:])
+}
+
+// reproducibleDir creates the working directory within baseDir,
+// with the name derived from the scenario seed.
+// The directory of a previous run with the same seed is removed in clean mode only.
+func (env *Env) reproducibleDir(baseDir string) (string, error) {
+	if baseDir == "" {
+		baseDir = os.TempDir()
+	}
+	dir := filepath.Join(baseDir, "jnode_VIT_"+env.deriveSeed("working_dir", 0)[:16])
+
+	if _, err := os.Stat(dir); err == nil {
+		if !env.Config.Clean {
+			return "", fmt.Errorf("[%s] already exists from a previous run with the same seed - remove it or use clean mode (-clean)", dir)
+		}
+		log.Printf("VIT - removing the previous working directory: %s", dir)
+		if err = os.RemoveAll(dir); err != nil {
+			return "", err
+		}
+	}
+
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
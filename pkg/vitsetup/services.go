@@ -0,0 +1,218 @@
+package vitsetup
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/input-output-hk/jorvit/internal/supervisor"
+	"github.com/input-output-hk/jorvit/internal/webproxy"
+)
+
+// StartServices starts, under env.Supervisor, the node and the vit station (when enabled in config),
+// the internal REST api proxy, the voting phase clock, the assets watcher,
+// the voteplans submission and the automatic tally (when enabled).
+// The node and the station are probed, an error (with their stderr tail) is returned
+// if they fail or they are not ready within the config ready timeout.
+// Use Wait and Stop to handle the services lifecycle.
+func (env *Env) StartServices() error {
+	var err error
+
+	// the sqlite stores are reloaded from the station DB, not from the assets files
+	if env.Config.Assets.Watch && env.Config.Proxy.Datastore == "sqlite" {
+		return fmt.Errorf("[%s] - not supported with %s: %s", "watch-assets", "proxy-datastore", env.Config.Proxy.Datastore)
+	}
+
+	if env.Config.Vote.AutoTally {
+		if err = env.prepareTally(); err != nil {
+			return fmt.Errorf("%s: %w", "auto tally", err)
+		}
+	}
+
+	env.Supervisor = supervisor.New(env.StopTimeout)
+	env.Supervisor.OnExit = env.serviceExit
+
+	env.PhaseClock = NewPhaseClock(env)
+	go env.PhaseClock.Run()
+
+	// Run the node
+	if env.Config.Node.Start {
+		env.closeNodeLogs() // of a previous start
+		stdout, err := os.Create(filepath.Join(env.WorkingDir, "stdout.log"))
+		if err != nil {
+			return err
+		}
+		env.nodeLogs = append(env.nodeLogs, stdout)
+		stderr, err := os.Create(filepath.Join(env.WorkingDir, "stderr.log"))
+		if err != nil {
+			env.closeNodeLogs()
+			return err
+		}
+		env.nodeLogs = append(env.nodeLogs, stderr)
+		env.Node.Stdout, env.Node.Stderr = stdout, stderr
+
+		if err = os.Setenv("RUST_BACKTRACE", "full"); err != nil {
+			return fmt.Errorf("%s: %w", "Failed to set env (RUST_BACKTRACE=full)", err)
+		}
+
+		node := supervisor.NewProcess(func() *exec.Cmd {
+			cmd := exec.Command(env.JnodeBin, env.Node.BuildCmdArg()...)
+			cmd.Dir = env.Node.WorkingDir
+			cmd.Stdout = env.Node.Stdout
+			cmd.Stderr = env.Node.Stderr
+			return cmd
+		})
+		opts := supervisor.Options{
+			Critical:     !env.Config.Node.AllowRestart, // otherwise it can be restarted manually
+			Ready:        env.nodeReady,
+			ReadyTimeout: env.ReadyTimeout,
+			MaxRestarts:  int(env.Config.Node.Restart.Max),
+			Backoff:      env.NodeRestartBackoff,
+		}
+		if err = env.Supervisor.Start(ServiceNode, node, opts); err != nil {
+			return fmt.Errorf("%s: %w", "node.Run FAILED", err)
+		}
+	}
+
+	if env.Config.Station.Start && !env.StationBuiltIn() {
+		station := supervisor.NewProcess(func() *exec.Cmd {
+			cmd := exec.Command(env.VstationBin, env.Station.BuildCmdArg()...)
+			cmd.Dir = env.Station.WorkingDir
+			cmd.Stdout = env.Station.Stdout
+			cmd.Stderr = env.Station.Stderr
+			return cmd
+		})
+		opts := supervisor.Options{
+			Critical:     true,
+			Ready:        env.stationReady,
+			ReadyTimeout: env.ReadyTimeout,
+			MaxRestarts:  int(env.Config.Station.Restart.Max),
+			Backoff:      env.StationRestartBackoff,
+		}
+		if err = env.Supervisor.Start(ServiceStation, station, opts); err != nil {
+			return fmt.Errorf("%s: %w", "vs.Run FAILED", err)
+		}
+	}
+
+	if env.Config.Proxy.Datastore == "sqlite" {
+		if err = env.LoadStationDB(); err != nil {
+			return fmt.Errorf("%s: %w", "LoadStationDB", err)
+		}
+	}
+
+	// built-in vit station, serving the same data of the proxy
+	if env.Config.Station.Start && env.StationBuiltIn() {
+		station := &webproxy.Proxy{
+			Proposals:  env.Proposals,
+			Funds:      env.Funds,
+			Challenges: env.Challenges,
+			Block0Bin:  &env.Block0Bin,
+			ApiTokens:  env.apiTokens(nil),
+		}
+		srv := supervisor.NewServer(env.Config.Station.Listen, webproxy.NewStation(station), env.TlsCertFile, env.TlsKeyFile)
+		if err = env.Supervisor.Start(ServiceStation, srv, supervisor.Options{Critical: true}); err != nil {
+			return fmt.Errorf("%s: %w", "built-in vit station", err)
+		}
+	}
+
+	////////////////////
+	// internal proxy //
+	////////////////////
+
+	proxy := &webproxy.Proxy{
+		Proposals:           env.Proposals,
+		Funds:               env.Funds,
+		Challenges:          env.Challenges,
+		Block0Bin:           &env.Block0Bin,
+		ReverseProxyAddress: "http://" + env.Config.Node.Rest,
+		ApiTokens:           env.apiTokens(env.Config.Proxy.ApiTokenExempt),
+		Status:              func() interface{} { return env.Status() },
+		Phases:              env.PhaseClock,
+	}
+	srv := supervisor.NewServer(env.Config.Proxy.Listen, proxy.Handler(), env.TlsCertFile, env.TlsKeyFile)
+	if err = env.Supervisor.Start(ServiceProxy, srv, supervisor.Options{Critical: true}); err != nil {
+		return fmt.Errorf("%s: %w", "Proxy Run", err)
+	}
+
+	if env.Config.Assets.Watch {
+		env.watchStop = make(chan struct{})
+		go env.WatchAssets(WatchInterval, env.watchStop)
+	}
+
+	if env.Config.Vote.SubmitVotePlan || env.Config.Vote.AutoTally {
+		env.txStop = make(chan struct{})
+	}
+	if env.Config.Vote.SubmitVotePlan {
+		go env.SubmitVotePlans(env.txStop)
+	}
+	if env.Config.Vote.AutoTally {
+		go env.RunTally(env.txStop)
+	}
+
+	return nil
+}
+
+// Supervised services names.
+const (
+	ServiceNode    = "node"
+	ServiceStation = "vit station"
+	ServiceProxy   = "proxy"
+)
+
+// serviceExit logs the unexpected services exits.
+func (env *Env) serviceExit(exit supervisor.Exit) {
+	if exit.Requested {
+		return
+	}
+	log.Printf("***** %s", exit)
+	if exit.Name == ServiceNode && !exit.Restart && env.Config.Node.AllowRestart {
+		log.Println("The node has stopped. Please start the node manually and keep the same running config or issue SIGINT/SIGTERM.")
+	}
+}
+
+// Wait blocks until SIGINT/SIGTERM is received (nil returned)
+// or a critical service (proxy, vit station, node when manual restart is not allowed)
+// exits and the automatic restarts, if any, are exhausted.
+func (env *Env) Wait() error {
+	return env.Supervisor.Wait()
+}
+
+// Stop stops gracefully all the services (forced after the config stop timeout)
+// and returns their exit status.
+func (env *Env) Stop() []supervisor.Exit {
+	defer env.closeNodeLogs() // once the node exited
+
+	if env.Supervisor == nil {
+		return nil
+	}
+	if env.watchStop != nil {
+		close(env.watchStop)
+		env.watchStop = nil
+	}
+	if env.txStop != nil {
+		close(env.txStop)
+		env.txStop = nil
+	}
+	if env.PhaseClock != nil {
+		env.PhaseClock.Stop() // ends the proxy phase events streams
+	}
+	return env.Supervisor.Stop()
+}
+
+// closeNodeLogs closes the node log files, if open.
+func (env *Env) closeNodeLogs() {
+	for _, f := range env.nodeLogs {
+		if err := f.Close(); err != nil {
+			log.Printf("node log [%s] close: %s", f.Name(), err)
+		}
+	}
+	env.nodeLogs = nil
+}
+
+// StationBuiltIn reports whether the vit station api is served by jorvit itself,
+// either because requested or because vit-servicing-station-server was not found.
+func (env *Env) StationBuiltIn() bool {
+	return env.Config.Station.BuiltIn || env.VstationBin == ""
+}
@@ -0,0 +1,155 @@
+package vitsetup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gocarina/gocsv"
+	"github.com/input-output-hk/jorvit/internal/datastore"
+	"github.com/input-output-hk/jorvit/internal/loader"
+	"github.com/input-output-hk/jorvit/pkg/vcli"
+	"github.com/input-output-hk/jorvit/pkg/vstation"
+)
+
+// WriteStationData dumps the funds, voteplans, proposals and challenges data as CSV,
+// loads them (and the API-Token values) into the station DB (when vit-servicing-station-cli is available)
+// and prepares the station to be started.
+func (env *Env) WriteStationData() error {
+	var err error
+
+	// FUNDS - dump
+	env.FundsFile = filepath.Join(env.VitStationDir, "sql_funds.csv")
+	if err = marshalCsvFile(env.Funds.All(), env.FundsFile); err != nil {
+		return fmt.Errorf("%s: %w", "Funds csv", err)
+	}
+
+	// VOTEPLANS - dump
+	env.VotePlansFile = filepath.Join(env.VitStationDir, "sql_voteplans.csv")
+	vp := make([]loader.ChainVotePlan, 0, len(env.VotePlans))
+	for _, fund := range *env.Funds.All() {
+		vp = append(vp, fund.VotePlans...)
+	}
+	if err = marshalCsvFile(&vp, env.VotePlansFile); err != nil {
+		return fmt.Errorf("%s: %w", "Voteplans csv", err)
+	}
+
+	// PROPOSALS - dump
+	env.ProposalsFile = filepath.Join(env.VitStationDir, "sql_proposals.csv")
+	if err = marshalCsvFile(env.Proposals.All(), env.ProposalsFile); err != nil {
+		return fmt.Errorf("%s: %w", "Proposals csv", err)
+	}
+
+	// CHALLENGES - dump
+	env.ChallengesFile = filepath.Join(env.VitStationDir, "sql_challenges.csv")
+	if err = marshalCsvFile(env.Challenges.All(), env.ChallengesFile); err != nil {
+		return fmt.Errorf("%s: %w", "Challenges csv", err)
+	}
+
+	//////////////////////
+	// VIT station data //
+	//////////////////////
+
+	env.VitDb = filepath.Join(env.VitStationDir, "database.sqlite3")
+	env.VitCfgFile = filepath.Join(env.VitStationDir, "vit_cfg.json")
+
+	if env.VcliBin != "" {
+		// init database
+		out, err := vcli.DbInit(env.VitDb)
+		if err != nil {
+			return cmdErr(err, "vcli.DbInit", out)
+		}
+
+		// populate the database with already dumped data
+		out, err = vcli.CsvDataLoad(env.VitDb, env.FundsFile, env.ProposalsFile, env.ChallengesFile, env.VotePlansFile)
+		if err != nil {
+			return cmdErr(err, "vcli.CsvDataLoad", out)
+		}
+	}
+
+	// vit-servicing-station-server
+	vs := vstation.NewVstation()
+	vs.WorkingDir = env.VitStationDir
+	vs.Address = env.Config.Station.Listen
+	vs.Block0Path = env.Block0BinFile
+	vs.DbUrl = env.VitDb
+	vs.Log.LogLevel = env.Config.Station.LogLevel
+	vs.Log.LogOutputPath = filepath.Join(env.VitStationDir, "vit_station.log")
+	vs.Cors.AllowedOrigins = strings.Split(env.Config.Node.Cors, ",")
+
+	vs.Tls.CertFile = env.TlsCertFile
+	vs.Tls.PrivKeyFile = env.TlsKeyFile
+
+	// API-Token
+	if len(env.ApiTokens) > 0 {
+		vs.EnableApiTokens = true
+		if env.VcliBin != "" {
+			out, err := vcli.ApiTokenAdd(nil, env.VitDb, env.ApiTokens)
+			if err != nil {
+				return cmdErr(err, "vcli.ApiTokenAdd", out)
+			}
+		}
+	}
+
+	vsJson, err := json.MarshalIndent(&vs, "", " ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", "vstation json.MarshalIndent", err)
+	}
+	if err = ioutil.WriteFile(env.VitCfgFile, vsJson, 0755); err != nil {
+		return fmt.Errorf("%s %s: %w", "vstation ioutil.WriteFile", env.VitCfgFile, err)
+	}
+
+	env.Station = vs
+
+	return nil
+}
+
+// marshalCsvFile creates filename and writes in it the CSV representation of data.
+func marshalCsvFile(data interface{}, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = gocsv.MarshalFile(data, file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadStationDB replaces the proposals, funds and challenges stores with the ones
+// read from the vit station DB, so the proxy serves the same data of the station.
+// Differences with the assets data are logged.
+func (env *Env) LoadStationDB() error {
+	if env.VcliBin == "" {
+		return fmt.Errorf("%s not generated, %s not found", env.VitDb, "vit-servicing-station-cli")
+	}
+
+	proposals := &datastore.SqliteProposals{TimeFormat: env.Config.TimeFormat}
+	if err := proposals.Initialize(env.VitDb); err != nil {
+		return fmt.Errorf("%s: %w", "proposals", err)
+	}
+	funds := &datastore.SqliteFunds{TimeFormat: env.Config.TimeFormat}
+	if err := funds.Initialize(env.VitDb); err != nil {
+		return fmt.Errorf("%s: %w", "funds", err)
+	}
+	challenges := &datastore.SqliteChallenges{}
+	if err := challenges.Initialize(env.VitDb); err != nil {
+		return fmt.Errorf("%s: %w", "challenges", err)
+	}
+
+	diff := append(datastore.CompareProposals(env.Proposals, proposals), datastore.CompareFunds(env.Funds, funds)...)
+	for _, d := range diff {
+		log.Printf("***** vit station DB differs from assets - %s", d)
+	}
+	if len(diff) == 0 {
+		log.Printf("vit station DB data matches the assets")
+	}
+
+	env.Proposals, env.Funds, env.Challenges = proposals, funds, challenges
+	return nil
+}
@@ -0,0 +1,149 @@
+package vitsetup
+
+import (
+	"time"
+
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/input-output-hk/jorvit/internal/supervisor"
+)
+
+// Status is the VIT environment status served by the proxy (/api/v0/jorvit/status).
+type Status struct {
+	GenesisHash string              `json:"genesis_hash"`
+	WorkingDir  string              `json:"working_dir"`
+	Services    []supervisor.Status `json:"services"`
+	Versions    StatusVersions      `json:"versions"`
+	Time        StatusTime          `json:"time"`
+	VotePlans   []StatusVotePlan    `json:"voteplans"`
+	Counts      StatusCounts        `json:"counts"`
+}
+
+// StatusVersions are the versions of the binaries in use, empty if not found.
+type StatusVersions struct {
+	Jcli     string `json:"jcli"`
+	Jnode    string `json:"jormungandr"`
+	Vcli     string `json:"vit_station_cli"`
+	Vstation string `json:"vit_station"`
+}
+
+// StatusTime is the current chain time together with the vote timing of each fund.
+type StatusTime struct {
+	Now       time.Time        `json:"now"`
+	ChainTime ChainTime        `json:"chain_time"`
+	Phase     Phase            `json:"phase"` // of the current fund
+	Genesis   time.Time        `json:"genesis"`
+	Funds     []StatusFundTime `json:"funds"`
+}
+
+// StatusFundTime is the resolved vote timing of a fund, as used by its voteplans.
+type StatusFundTime struct {
+	FundID       uint64   `json:"fund_id"`
+	Phase        Phase    `json:"phase"`
+	VoteStart    StatusAt `json:"vote_start"`
+	VoteEnd      StatusAt `json:"vote_end"`
+	CommitteeEnd StatusAt `json:"committee_end"`
+}
+
+// StatusAt is a point in time, in both time and chain time.
+type StatusAt struct {
+	Time      time.Time `json:"time"`
+	ChainTime ChainTime `json:"chain_time"`
+}
+
+// StatusVotePlan is a voteplan with its own fund timing.
+type StatusVotePlan struct {
+	ID           string    `json:"id"`
+	FundID       uint64    `json:"fund_id"`
+	Payload      string    `json:"payload_type"`
+	Proposals    int       `json:"proposals"`
+	VoteStart    ChainTime `json:"vote_start"`
+	VoteEnd      ChainTime `json:"vote_end"`
+	CommitteeEnd ChainTime `json:"committee_end"`
+}
+
+// StatusCounts are the number of loaded data items.
+type StatusCounts struct {
+	Proposals  int `json:"proposals"`
+	Funds      int `json:"funds"`
+	Challenges int `json:"challenges"`
+	VotePlans  int `json:"voteplans"`
+	Leaders    int `json:"leaders"`
+	Voters     int `json:"voters"`
+}
+
+// Status returns the current environment status.
+// A node not started by jorvit is reported as running when its REST api is ready.
+func (env *Env) Status() *Status {
+	now := time.Now().UTC()
+
+	status := &Status{
+		GenesisHash: env.Block0Hash,
+		WorkingDir:  env.WorkingDir,
+		Services:    []supervisor.Status{},
+		Versions: StatusVersions{
+			Jcli:     kit.B2S(env.JcliVersion),
+			Jnode:    kit.B2S(env.JnodeVersion),
+			Vcli:     kit.B2S(env.VcliVersion),
+			Vstation: kit.B2S(env.VstationVersion),
+		},
+		Time: StatusTime{
+			Now:       now,
+			ChainTime: env.ChainTime(now),
+			Phase:     env.PhaseAt(now).Phase,
+			Genesis:   env.GenesisTime,
+			Funds:     []StatusFundTime{},
+		},
+		VotePlans: make([]StatusVotePlan, len(env.VotePlans)),
+		Counts: StatusCounts{
+			VotePlans: len(env.VotePlans),
+			Leaders:   len(env.Leaders),
+		},
+	}
+
+	if !env.Config.Node.Start {
+		node := supervisor.Status{Name: ServiceNode}
+		if err := env.nodeReady(); err != nil {
+			node.LastErr = err.Error()
+		} else {
+			node.Running = true
+		}
+		status.Services = append(status.Services, node)
+	}
+	if env.Supervisor != nil {
+		status.Services = append(status.Services, env.Supervisor.Status()...)
+	}
+
+	for _, ft := range env.fundsTimingByStart() {
+		status.Time.Funds = append(status.Time.Funds, StatusFundTime{
+			FundID:       ft.FundID,
+			Phase:        fundPhaseAt(ft, now).Phase,
+			VoteStart:    StatusAt{Time: ft.VoteStartTime, ChainTime: ft.VoteStart},
+			VoteEnd:      StatusAt{Time: ft.VoteEndTime, ChainTime: ft.VoteEnd},
+			CommitteeEnd: StatusAt{Time: ft.CommitteeEndTime, ChainTime: ft.CommitteeEnd},
+		})
+	}
+
+	for i, vp := range env.VotePlans {
+		status.VotePlans[i] = StatusVotePlan{
+			ID:           vp.VotePlanID,
+			FundID:       vp.FundID,
+			Payload:      vp.Payload,
+			Proposals:    len(vp.Proposals),
+			VoteStart:    vp.VoteStart,
+			VoteEnd:      vp.VoteEnd,
+			CommitteeEnd: vp.CommitteeEnd,
+		}
+	}
+
+	if env.Proposals != nil {
+		status.Counts.Proposals = env.Proposals.Total()
+	}
+	if env.Funds != nil {
+		status.Counts.Funds = env.Funds.Total()
+	}
+	if env.Challenges != nil {
+		status.Counts.Challenges = env.Challenges.Total()
+	}
+
+	return status
+}
@@ -0,0 +1,145 @@
+package vitsetup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/input-output-hk/jorvit/pkg/jvote"
+	"github.com/rinor/jorcli/jcli"
+)
+
+// prepareTally checks the automatic tally requirements and creates the "tally" working sub folder.
+func (env *Env) prepareTally() error {
+	if err := env.checkCertTx(env.Config.Fees.Certificate); err != nil {
+		return err
+	}
+
+	env.TallyDir = filepath.Join(env.WorkingDir, "tally")
+	if err := os.MkdirAll(env.TallyDir, 0755); err != nil {
+		return fmt.Errorf("%s: %w", "tallyDir", err)
+	}
+	return nil
+}
+
+// RunTally tallies the voteplans of each fund once its tally window opens (vote end),
+// until stop is closed. Public voteplans get the vote tally certificate directly,
+// the private ones are first encrypted tallied, then decrypted with the generated
+// committee member key and the decrypted tally is submitted.
+// The transactions are signed by the first BFT leader (also a committee member).
+func (env *Env) RunTally(stop <-chan struct{}) {
+	timings := make([]FundTiming, 0, len(env.FundsTiming))
+	for _, ft := range env.FundsTiming {
+		timings = append(timings, ft)
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		return timings[i].VoteEndTime.Before(timings[j].VoteEndTime)
+	})
+
+	for _, ft := range timings {
+		if time.Now().After(ft.CommitteeEndTime) {
+			log.Printf("***** VIT - TALLY: fund [%d] - tally window already closed (%s)", ft.FundID, ft.CommitteeEnd)
+			continue
+		}
+
+		// one slot later, so the vote end is surely reached on chain
+		timer := time.NewTimer(time.Until(ft.VoteEndTime.Add(env.SlotDuration)))
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return
+		}
+
+		for _, vp := range env.VotePlans {
+			if vp.FundID != ft.FundID {
+				continue
+			}
+			if err := env.tallyVotePlan(vp, ft.CommitteeEndTime, stop); err != nil {
+				log.Printf("***** VIT - TALLY: %s voteplan %s FAILED: %s", vp.Payload, vp.VotePlanID, err)
+				continue
+			}
+			log.Printf("VIT - TALLY: %s voteplan %s - DONE", vp.Payload, vp.VotePlanID)
+		}
+	}
+}
+
+// tallyVotePlan builds and submits the vote tally certificate(s) of vp.
+func (env *Env) tallyVotePlan(vp VotePlan, deadline time.Time, stop <-chan struct{}) error {
+	var (
+		id   = vp.VotePlanID
+		file = func(suffix string) string { return filepath.Join(env.TallyDir, id+suffix) }
+	)
+
+	switch vp.Payload {
+	case "public":
+		cert, err := jvote.CertificateNewVoteTallyPublic(id, file(".cert"))
+		if err != nil {
+			return cmdErr(err, "jvote.CertificateNewVoteTallyPublic", cert)
+		}
+		_, err = env.submitCertificate(kit.B2S(cert), env.Config.Fees.Certificate, file(""), "TALLY", deadline, stop)
+		return err
+
+	case "private":
+		if env.CommitteeMemberSKFile == "" {
+			return fmt.Errorf("%s provided, the private tally has to be done by the committee members", "committee-privacy-public-key")
+		}
+
+		// encrypted tally, needed to generate the decryption shares
+		encCert, err := jcli.CertificateNewEncryptedVoteTally(id, file("_encrypted.cert"))
+		if err != nil {
+			return cmdErr(err, "jcli.CertificateNewEncryptedVoteTally", encCert)
+		}
+		if _, err = env.submitCertificate(kit.B2S(encCert), env.Config.Fees.Certificate, file("_encrypted"), "TALLY", deadline, stop); err != nil {
+			return err
+		}
+
+		plans, err := jvote.RestVotePlans(env.nodeRestAPI(), "json")
+		if err != nil {
+			return cmdErr(err, "jvote.RestVotePlans", plans)
+		}
+		if err = ioutil.WriteFile(file("_voteplans.json"), plans, 0644); err != nil {
+			return fmt.Errorf("%s: %w", "voteplans status WRITE", err)
+		}
+
+		shares, err := jvote.TallyDecryptionShares(file("_voteplans.json"), id, env.CommitteeMemberSKFile)
+		if err != nil {
+			return cmdErr(err, "jvote.TallyDecryptionShares", shares)
+		}
+		if err = ioutil.WriteFile(file("_shares.json"), shares, 0644); err != nil {
+			return fmt.Errorf("%s: %w", "decryption shares WRITE", err)
+		}
+
+		merged, err := jvote.TallyMergeShares([]string{file("_shares.json")})
+		if err != nil {
+			return cmdErr(err, "jvote.TallyMergeShares", merged)
+		}
+		if err = ioutil.WriteFile(file("_merged_shares.json"), merged, 0644); err != nil {
+			return fmt.Errorf("%s: %w", "merged shares WRITE", err)
+		}
+
+		results, err := jvote.TallyDecryptResults(file("_voteplans.json"), id, file("_merged_shares.json"), uint(len(env.PrivacyPublicKeys)), "json")
+		if err != nil {
+			return cmdErr(err, "jvote.TallyDecryptResults", results)
+		}
+		if err = ioutil.WriteFile(file("_results.json"), results, 0644); err != nil {
+			return fmt.Errorf("%s: %w", "decrypted results WRITE", err)
+		}
+		log.Printf("VIT - TALLY: private voteplan %s - decrypted results: %s", id, file("_results.json"))
+
+		cert, err := jvote.CertificateNewVoteTallyPrivate(file("_voteplans.json"), id, file("_merged_shares.json"), file(".cert"))
+		if err != nil {
+			return cmdErr(err, "jvote.CertificateNewVoteTallyPrivate", cert)
+		}
+		_, err = env.submitCertificate(kit.B2S(cert), env.Config.Fees.Certificate, file(""), "TALLY", deadline, stop)
+		return err
+
+	default:
+		return fmt.Errorf("unknown payload type [%s]", vp.Payload)
+	}
+}
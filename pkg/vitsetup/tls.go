@@ -0,0 +1,204 @@
+package vitsetup
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BuildTls prepares the proxy and vit station HTTPS certificate (when enabled).
+// A provided cert/key pair is checked, otherwise a new CA and a server certificate
+// signed by it are generated in the "tls" working sub folder.
+// The CA certificate (ca.crt) is the one to be trusted by the clients (ex: mobile devices).
+func (env *Env) BuildTls() error {
+	var err error
+
+	if !env.Config.Tls.Enabled() {
+		return nil
+	}
+
+	if !env.Config.Tls.SelfSigned {
+		// absolute paths, since the station runs within its own folder
+		if env.TlsCertFile, err = filepath.Abs(env.Config.Tls.CertFile); err != nil {
+			return fmt.Errorf("%s: %w", "tls cert", err)
+		}
+		if env.TlsKeyFile, err = filepath.Abs(env.Config.Tls.KeyFile); err != nil {
+			return fmt.Errorf("%s: %w", "tls key", err)
+		}
+		if _, err = tls.LoadX509KeyPair(env.TlsCertFile, env.TlsKeyFile); err != nil {
+			return fmt.Errorf("%s: %w", "tls.LoadX509KeyPair", err)
+		}
+		return nil
+	}
+
+	env.TlsDir = filepath.Join(env.WorkingDir, "tls")
+	if err = os.Mkdir(env.TlsDir, 0755); err != nil {
+		return fmt.Errorf("%s: %w", "tlsDir", err)
+	}
+
+	now := time.Now().Add(-time.Hour).UTC()
+
+	// CA
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "tls CA key", err)
+	}
+	caTmpl := &x509.Certificate{
+		Subject:               pkix.Name{Organization: []string{"jorvit"}, CommonName: "jorvit local CA"},
+		NotBefore:             now,
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	caDer, err := createCert(caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "tls CA cert", err)
+	}
+	caCert, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "tls CA cert", err)
+	}
+
+	// server
+	srvKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "tls server key", err)
+	}
+	srvTmpl := &x509.Certificate{
+		Subject:     pkix.Name{Organization: []string{"jorvit"}, CommonName: "localhost"},
+		NotBefore:   now,
+		NotAfter:    now.AddDate(1, 0, 0),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range env.tlsHosts() {
+		if ip := net.ParseIP(host); ip != nil {
+			srvTmpl.IPAddresses = append(srvTmpl.IPAddresses, ip)
+		} else {
+			srvTmpl.DNSNames = append(srvTmpl.DNSNames, host)
+		}
+	}
+	srvDer, err := createCert(srvTmpl, caCert, &srvKey.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "tls server cert", err)
+	}
+
+	env.TlsCaFile = filepath.Join(env.TlsDir, "ca.crt")
+	env.TlsCertFile = filepath.Join(env.TlsDir, "server.crt")
+	env.TlsKeyFile = filepath.Join(env.TlsDir, "server.key")
+
+	if err = writePem(env.TlsCaFile, "CERTIFICATE", caDer, 0644); err != nil {
+		return err
+	}
+	if err = writePemKey(filepath.Join(env.TlsDir, "ca.key"), caKey); err != nil {
+		return err
+	}
+	if err = writePem(env.TlsCertFile, "CERTIFICATE", srvDer, 0644); err != nil {
+		return err
+	}
+	if err = writePemKey(env.TlsKeyFile, srvKey); err != nil {
+		return err
+	}
+
+	log.Printf("TLS - self signed CA (to be trusted by the clients): %s", env.TlsCaFile)
+	log.Printf("TLS - server certificate hosts: %v %v", srvTmpl.DNSNames, srvTmpl.IPAddresses)
+
+	return nil
+}
+
+// TlsEnabled reports whether the proxy and the vit station are served over HTTPS.
+func (env *Env) TlsEnabled() bool {
+	return env.TlsCertFile != "" && env.TlsKeyFile != ""
+}
+
+// Scheme returns the proxy and vit station URL scheme, http or https.
+func (env *Env) Scheme() string {
+	if env.TlsEnabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// tlsHosts returns the generated server certificate hosts:
+// localhost, the proxy and station listen IPs (all the local ones when listening on any),
+// and the provided extra ones.
+func (env *Env) tlsHosts() []string {
+	var (
+		hosts = []string{"localhost", "127.0.0.1", "::1"}
+		seen  = map[string]bool{"localhost": true, "127.0.0.1": true, "::1": true}
+		add   = func(host string) {
+			if host != "" && !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+	)
+
+	for _, listen := range []string{env.Config.Proxy.Listen, env.Config.Station.Listen} {
+		host, _, err := net.SplitHostPort(listen)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsUnspecified() {
+			add(host)
+			continue
+		}
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				add(ipNet.IP.String())
+			}
+		}
+	}
+
+	for _, host := range env.Config.Tls.Hosts {
+		add(host)
+	}
+
+	return hosts
+}
+
+func createCert(tmpl *x509.Certificate, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	tmpl.SerialNumber = serial
+	return x509.CreateCertificate(rand.Reader, tmpl, parent, pub, signer)
+}
+
+func writePem(filename string, blockType string, der []byte, perm os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(filename, data, perm); err != nil {
+		return fmt.Errorf("%s %s: %w", "tls ioutil.WriteFile", filename, err)
+	}
+	return nil
+}
+
+// writePemKey writes key PKCS8 encoded.
+func writePemKey(filename string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", "tls key", filename, err)
+	}
+	return writePem(filename, "PRIVATE KEY", der, 0600)
+}
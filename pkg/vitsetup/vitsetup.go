@@ -0,0 +1,560 @@
+// Package vitsetup provides the steps needed to build and run a VIT environment.
+//
+// The steps are expected to be executed in order:
+//
+//	New -> LookupBinaries -> LoadAssets -> ResolveFundsTiming -> CreateWorkingDir ->
+//	BuildLeaders -> BuildVoters -> BuildVotePlans -> BuildBlock0 -> WriteNodeConfig -> BuildApiTokens ->
+//	BuildTls -> WriteStationData -> StartServices
+//
+// Setup runs all the steps up to (excluding) StartServices.
+package vitsetup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/input-output-hk/jorvit/internal/config"
+	"github.com/input-output-hk/jorvit/internal/datastore"
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/input-output-hk/jorvit/internal/supervisor"
+	"github.com/input-output-hk/jorvit/pkg/jvote"
+	"github.com/input-output-hk/jorvit/pkg/vcli"
+	"github.com/input-output-hk/jorvit/pkg/vstation"
+	"github.com/rinor/jorcli/jcli"
+	"github.com/rinor/jorcli/jnode"
+)
+
+// Config is the VIT scenario definition.
+type Config = config.Scenario
+
+// DefaultConfig returns a Config with the same defaults of the jorvit commandline flags.
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// LoadConfig returns the DefaultConfig updated with the values found in filename (YAML/JSON).
+func LoadConfig(filename string) (*Config, error) {
+	return config.Load(filename)
+}
+
+const (
+	consensus      = "bft" // bft or genesis_praos
+	discrimination = ""    // "" (empty defaults to "production")
+)
+
+// Env contains the VIT environment settings and the state built by each step.
+type Env struct {
+	Config *Config
+
+	// Resolved timing
+	GenesisTime      time.Time
+	SlotDuration     time.Duration
+	EpochDuration    time.Duration
+	VoteStartTime    time.Time
+	VoteEndTime      time.Time
+	CommitteeEndTime time.Time
+
+	VoteStart    ChainTime
+	VoteEnd      ChainTime
+	CommitteeEnd ChainTime
+
+	VoteDuration      time.Duration
+	CommitteeDuration time.Duration
+
+	// Resolved timing of each fund voteplans (by fund id)
+	FundsTiming map[uint64]FundTiming
+
+	// P2P
+	P2PListenAddress string
+
+	// Binaries lookup folders (local folder first, then PATH)
+	JorBinsDir string
+	VitBinsDir string
+
+	JcliBin         string
+	JcliVersion     []byte
+	JnodeBin        string
+	JnodeVersion    []byte
+	VcliBin         string // empty if not found
+	VcliVersion     []byte
+	VstationBin     string // empty if not found
+	VstationVersion []byte
+
+	// Directories
+	WorkingDir    string
+	VotePlanDir   string
+	TallyDir      string // automatic tally only
+	VotersDir     string // generated voters only
+	VitStationDir string
+
+	// Data
+	Proposals  datastore.ProposalsStore
+	Funds      datastore.FundsStore
+	Challenges datastore.ChallengesStore
+
+	Leaders           []Leader
+	PrivacyPublicKeys []string // provided + generated privacy committee members
+	VotePlans         []VotePlan
+	VoteEncKey        string
+
+	Voters       []Voter // generated voter wallets, funded on block0
+	VotersFile   string  // address, value, sk
+	VotersQrFile string  // address, pin, qr_payload
+
+	CommitteeMemberSKFile string // generated privacy committee member SK, empty when the members are provided
+
+	Block0Cfg     *jnode.Block0Config
+	Block0Bin     []byte
+	Block0Hash    string
+	Block0BinFile string
+	Block0TxtFile string
+
+	NodeCfgFile string
+
+	// Station data
+	FundsFile      string
+	VotePlansFile  string
+	ProposalsFile  string
+	ChallengesFile string
+	VitDb          string
+	VitCfgFile     string
+
+	// API-Token values accepted by the station and the proxy (provided + generated)
+	ApiTokens     []string
+	ApiTokensFile string
+
+	// Proxy and vit station HTTPS (empty if not enabled)
+	TlsDir      string
+	TlsCaFile   string // self signed CA only
+	TlsCertFile string
+	TlsKeyFile  string
+
+	// Services
+	Node    *jnode.Jnode
+	Station *vstation.Vstation
+
+	Supervisor   *supervisor.Supervisor
+	StopTimeout  time.Duration // services graceful stop timeout
+	ReadyTimeout time.Duration // time the started services have to become ready
+
+	NodeRestartBackoff    time.Duration
+	StationRestartBackoff time.Duration
+
+	PhaseClock *PhaseClock // voting phase tracking
+
+	leadersPubKey map[string]bool
+	watchStop     chan struct{}
+	txStop        chan struct{} // voteplans submission and tally
+	nodeLogs      []*os.File    // node stdout.log and stderr.log
+}
+
+// New validates the config and resolves the timing settings.
+// Missing optional values of cfg are updated with the resolved ones.
+func New(cfg *Config) (*Env, error) {
+	var err error
+
+	env := &Env{
+		Config:     cfg,
+		JorBinsDir: "jor_bins",
+		VitBinsDir: "vit_bins",
+	}
+
+	if cfg.Node.LogLevel == "" {
+		cfg.Node.LogLevel = "warn"
+	}
+	if cfg.Station.LogLevel == "" {
+		cfg.Station.LogLevel = "warn"
+	}
+
+	// check if file exist - duplicate data check is performed later on
+	for i := range cfg.Leaders.SecretKeys {
+		if _, err = os.Stat(cfg.Leaders.SecretKeys[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// set new value for bft leaders min if provided inputs are more
+	inputLeaders := uint(len(cfg.Leaders.SecretKeys) + len(cfg.Leaders.PublicKeys))
+	if inputLeaders > cfg.Leaders.Min {
+		cfg.Leaders.Min = inputLeaders
+	}
+
+	if cfg.TimeFormat == "" {
+		cfg.TimeFormat = time.RFC3339
+	}
+
+	pinnedGenesis := false
+	if cfg.Genesis.Time == "" {
+		cfg.Genesis.Time = time.Now().UTC().Format(time.RFC3339)
+		if cfg.IsReproducible() {
+			cfg.Genesis.Time = config.ReproducibleGenesisTime
+			pinnedGenesis = true
+		}
+	}
+	env.GenesisTime, err = time.Parse(time.RFC3339, cfg.Genesis.Time)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "genesisTime", err)
+	}
+
+	env.SlotDuration, err = time.ParseDuration(cfg.Genesis.SlotDuration)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "slotDuration", err)
+	}
+	switch {
+	case env.SlotDuration == 0:
+		return nil, fmt.Errorf("[%s] - cannot be 0", "slotDuration")
+	case env.SlotDuration%time.Second > 0:
+		return nil, fmt.Errorf("[%s] - smallest unit is [1s]", "slotDuration")
+	case env.SlotDuration > 255*time.Second:
+		return nil, fmt.Errorf("[%s] - max allowed value is [255s]", "slotDuration")
+	}
+
+	env.EpochDuration, err = parseSlotsDuration("epochDuration", cfg.Genesis.EpochDuration, env.SlotDuration)
+	if err != nil {
+		return nil, err
+	}
+	env.VoteDuration, err = parseSlotsDuration("voteDuration", cfg.Vote.Duration, env.SlotDuration)
+	if err != nil {
+		return nil, err
+	}
+	env.CommitteeDuration, err = parseSlotsDuration("committeeDuration", cfg.Vote.CommitteeDuration, env.SlotDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.StopTimeout == "" {
+		cfg.StopTimeout = supervisor.DefaultStopTimeout.String()
+	}
+	env.StopTimeout, err = time.ParseDuration(cfg.StopTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "stopTimeout", err)
+	}
+	if cfg.ReadyTimeout == "" {
+		cfg.ReadyTimeout = supervisor.DefaultReadyTimeout.String()
+	}
+	env.ReadyTimeout, err = time.ParseDuration(cfg.ReadyTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "readyTimeout", err)
+	}
+	env.NodeRestartBackoff, err = parseBackoff(cfg.Node.Restart.Backoff)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "node restart backoff", err)
+	}
+	env.StationRestartBackoff, err = parseBackoff(cfg.Station.Restart.Backoff)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "vit station restart backoff", err)
+	}
+
+	if cfg.Vote.Start == "" {
+		// the pinned genesis time is in the past, so would be the whole voting window
+		if pinnedGenesis && cfg.Node.Start {
+			return nil, fmt.Errorf("reproducible mode with the pinned genesis time [%s] needs the genesis time or the vote start to start the node", config.ReproducibleGenesisTime)
+		}
+		cfg.Vote.Start = cfg.Genesis.Time
+	}
+	env.VoteStartTime, err = parseSlotsTime("voteStart", cfg.Vote.Start, "genesisTime", env.GenesisTime, env.GenesisTime, env.SlotDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Vote.End == "" {
+		cfg.Vote.End = env.VoteStartTime.Add(env.VoteDuration).Format(time.RFC3339)
+	}
+	env.VoteEndTime, err = parseSlotsTime("voteEnd", cfg.Vote.End, "voteStart", env.VoteStartTime, env.GenesisTime, env.SlotDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Vote.CommitteeEnd == "" {
+		cfg.Vote.CommitteeEnd = env.VoteEndTime.Add(env.CommitteeDuration).Format(time.RFC3339)
+	}
+	env.CommitteeEndTime, err = parseSlotsTime("committeeEnd", cfg.Vote.CommitteeEnd, "voteEnd", env.VoteEndTime, env.GenesisTime, env.SlotDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	env.VoteStart = env.ChainTime(env.VoteStartTime)
+	env.VoteEnd = env.ChainTime(env.VoteEndTime)
+	env.CommitteeEnd = env.ChainTime(env.CommitteeEndTime)
+
+	switch {
+	case cfg.Assets.Proposals == "":
+		return nil, fmt.Errorf("[%s] - not provided", "proposals file")
+	case cfg.Assets.Fund == "":
+		return nil, fmt.Errorf("[%s] - not provided", "fund file")
+	case cfg.Assets.Challenges == "":
+		return nil, fmt.Errorf("[%s] - not provided", "challenges file")
+	case cfg.Leaders.Min == 0:
+		return nil, fmt.Errorf("[%s: %d] - wrong value", "bftLeaderTot", cfg.Leaders.Min)
+
+	case cfg.Proxy.Listen == "":
+		return nil, fmt.Errorf("[%s] - not set", "proxy")
+	case cfg.Node.Rest == "":
+		return nil, fmt.Errorf("[%s] - not set", "rest")
+	case cfg.Node.Listen == "":
+		return nil, fmt.Errorf("[%s] - not set", "node")
+
+	case cfg.Station.Listen == "":
+		return nil, fmt.Errorf("[%s] - not set", "vit-station")
+
+	case cfg.Proxy.Datastore != "" && cfg.Proxy.Datastore != "memory" && cfg.Proxy.Datastore != "sqlite":
+		return nil, fmt.Errorf("[%s: %s] - expected to be one of (%s, %s)", "proxy-datastore", cfg.Proxy.Datastore, "memory", "sqlite")
+	case cfg.Proxy.Datastore == "sqlite" && cfg.Assets.Watch:
+		return nil, fmt.Errorf("[%s] - not supported with %s: %s", "watch-assets", "proxy-datastore", cfg.Proxy.Datastore)
+
+	case cfg.Tls.SelfSigned && (cfg.Tls.CertFile != "" || cfg.Tls.KeyFile != ""):
+		return nil, fmt.Errorf("[%s] - not supported with %s", "tls-self-signed", "tls-cert/tls-key")
+	case !cfg.Tls.SelfSigned && (cfg.Tls.CertFile == "") != (cfg.Tls.KeyFile == ""):
+		return nil, fmt.Errorf("[%s] - both %s and %s are needed", "tls", "tls-cert", "tls-key")
+
+	case cfg.Vote.VotePlanProposalsMax < 1:
+		return nil, fmt.Errorf("[%s: %d] - wrong value, expected > 0", "votePlanProposalsMax", cfg.Vote.VotePlanProposalsMax)
+	}
+
+	nodeListen := strings.Split(cfg.Node.Listen, ":")
+	if len(nodeListen) != 2 {
+		return nil, fmt.Errorf("[%s: %s] - expected IP:PORT format", "node", cfg.Node.Listen)
+	}
+	nodePort, err := strconv.Atoi(nodeListen[1])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", "nodePort", err)
+	}
+
+	var (
+		p2pIPver, p2pProto           = "ip4", "tcp"
+		p2pListenAddr, p2pListenPort = nodeListen[0], nodePort
+	)
+	env.P2PListenAddress = "/" + p2pIPver + "/" + p2pListenAddr + "/" + p2pProto + "/" + strconv.Itoa(p2pListenPort)
+
+	return env, nil
+}
+
+// parseSlotsDuration parses a duration that has to be a multiple of slotDur.
+func parseSlotsDuration(name string, value string, slotDur time.Duration) (time.Duration, error) {
+	dur, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	switch {
+	case dur == 0:
+		return 0, fmt.Errorf("[%s] - cannot be 0", name)
+	case dur%time.Second > 0:
+		return 0, fmt.Errorf("[%s] - smallest unit is [1s]", name)
+	case dur%slotDur > 0:
+		return 0, fmt.Errorf("[%s: %s] - should be multiple of [%s: %s].", name, dur.String(), "SlotDuration", slotDur.String())
+	}
+	return dur, nil
+}
+
+// parseSlotsTime parses a RFC3339 time that can't be before `after`,
+// and has to be at slotDur steps from genesis.
+func parseSlotsTime(name string, value string, afterName string, after time.Time, genesis time.Time, slotDur time.Duration) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("%s: %w", name, err)
+	}
+	switch {
+	case t.Sub(after) < 0:
+		return t, fmt.Errorf("%s: [%s] can't be smaller than %s: [%s]", name, value, afterName, after.Format(time.RFC3339))
+	case t.Sub(genesis)%slotDur != 0:
+		return t, fmt.Errorf("%s: [%s] needs to have %s: [%s] steps from %s: [%s]", name, value, "SlotDuration", slotDur.String(), "genesisTime", genesis.Format(time.RFC3339))
+	}
+	return t, nil
+}
+
+// LookupBinaries searches for the needed binaries and retrieves their versions.
+// jcli and jormungandr are mandatory, while the vit-servicing-station ones are optional.
+func (env *Env) LookupBinaries() error {
+	var err error
+
+	// Check for jcli binary. Local folder first (jor_bins), then PATH
+	env.JcliBin, err = kit.FindExecutable("jcli", env.JorBinsDir)
+	if err != nil {
+		return err
+	}
+	jcli.BinName(env.JcliBin)
+	jvote.BinName(env.JcliBin)
+
+	env.JcliVersion, err = jcli.VersionFull()
+	if err != nil {
+		return cmdErr(err, "jcli.VersionFull", env.JcliVersion)
+	}
+
+	// Check for jörmungandr binary. Local folder first, then PATH
+	env.JnodeBin, err = kit.FindExecutable("jormungandr", env.JorBinsDir)
+	if err != nil {
+		return err
+	}
+	jnode.BinName(env.JnodeBin)
+
+	env.JnodeVersion, err = jnode.VersionFull()
+	if err != nil {
+		return cmdErr(err, "jnode.VersionFull", env.JnodeVersion)
+	}
+
+	// Check for vit-servicing-station-cli binary. Local folder first (vit_bins), then PATH
+	env.VcliBin, err = kit.FindExecutable("vit-servicing-station-cli", env.VitBinsDir)
+	if err != nil {
+		log.Printf("***** %s - DB data related to %s will NOT be generated", err.Error(), "vit-servicing-station")
+		env.VcliBin = ""
+	} else {
+		vcli.BinName(env.VcliBin)
+		env.VcliVersion, err = vcli.Version()
+		if err != nil {
+			return cmdErr(err, "vcli.Version", env.VcliVersion)
+		}
+	}
+
+	// Check for vit-servicing-station-server binary. Local folder first (vit_bins), then PATH
+	env.VstationBin, err = kit.FindExecutable("vit-servicing-station-server", env.VitBinsDir)
+	if err != nil {
+		log.Printf("***** %s - the built-in vit station will be used", err.Error())
+		env.VstationBin = ""
+	} else {
+		vstation.BinName(env.VstationBin)
+		env.VstationVersion, err = vstation.Version()
+		if err != nil {
+			return cmdErr(err, "vstation.Version", env.VstationVersion)
+		}
+	}
+
+	return nil
+}
+
+// LoadAssets loads the proposals, funds and challenges data files,
+// and checks that every proposal belongs to a known fund and challenge.
+func (env *Env) LoadAssets() error {
+	if err := env.loadProposals(env.Config.Assets.Proposals); err != nil {
+		return fmt.Errorf("%s: %w", "loadProposals", err)
+	}
+	if err := env.loadFundInfo(env.Config.Assets.Fund); err != nil {
+		return fmt.Errorf("%s: %w", "loadFundInfo", err)
+	}
+	if err := env.loadChallenges(env.Config.Assets.Challenges); err != nil {
+		return fmt.Errorf("%s: %w", "loadChallenges", err)
+	}
+	if err := env.linkProposalsFunds(); err != nil {
+		return fmt.Errorf("%s: %w", "linkProposalsFunds", err)
+	}
+	if err := env.linkProposalsChallenges(); err != nil {
+		return fmt.Errorf("%s: %w", "linkProposalsChallenges", err)
+	}
+	return nil
+}
+
+func (env *Env) loadProposals(file string) error {
+	defer timeTrack(time.Now(), "Proposals File load")
+	env.Proposals = &datastore.Proposals{}
+	return env.Proposals.Initialize(file)
+}
+
+func (env *Env) loadFundInfo(file string) error {
+	defer timeTrack(time.Now(), "Fund File load")
+	env.Funds = &datastore.Funds{}
+	return env.Funds.Initialize(file)
+}
+
+func (env *Env) loadChallenges(file string) error {
+	defer timeTrack(time.Now(), "Challenges File load")
+	env.Challenges = &datastore.Challenges{}
+	return env.Challenges.Initialize(file)
+}
+
+// CreateWorkingDir creates a new working directory within baseDir,
+// together with the voteplans and vit station sub folders,
+// and saves the resolved config as "scenario.yaml".
+func (env *Env) CreateWorkingDir(baseDir string) error {
+	var err error
+
+	if env.Config.IsReproducible() {
+		env.WorkingDir, err = env.reproducibleDir(baseDir)
+	} else {
+		env.WorkingDir, err = ioutil.TempDir(baseDir, "jnode_VIT_")
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", "workingDir", err)
+	}
+	log.Printf("Working Directory: %s", env.WorkingDir)
+
+	// keep the resolved scenario, so the same setup can be reproduced with -config
+	cfgYaml, err := env.Config.ToYaml()
+	if err != nil {
+		return fmt.Errorf("%s: %w", "scenario ToYaml", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(env.WorkingDir, "scenario.yaml"), cfgYaml, 0644)
+	if err != nil {
+		return fmt.Errorf("%s: %w", "scenario WRITE", err)
+	}
+
+	// directory to dump the voteplan(s) config(s) and certificate(s)
+	env.VotePlanDir = filepath.Join(env.WorkingDir, "vote_plans")
+	if err = os.Mkdir(env.VotePlanDir, 0755); err != nil {
+		return fmt.Errorf("%s: %w", "votePlanDir", err)
+	}
+
+	// directory to dump the vit servicing station configs
+	env.VitStationDir = filepath.Join(env.WorkingDir, "vit_station")
+	if err = os.Mkdir(env.VitStationDir, 0755); err != nil {
+		return fmt.Errorf("%s: %w", "vitStationDir", err)
+	}
+
+	return nil
+}
+
+// Setup runs all the steps needed to build the VIT environment inside baseDir,
+// without starting any service.
+func Setup(cfg *Config, baseDir string) (*Env, error) {
+	env, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := []struct {
+		name string
+		fn   func() error
+	}{
+		{"LookupBinaries", env.LookupBinaries},
+		{"LoadAssets", env.LoadAssets},
+		{"ResolveFundsTiming", env.ResolveFundsTiming},
+		{"CreateWorkingDir", func() error { return env.CreateWorkingDir(baseDir) }},
+		{"BuildLeaders", env.BuildLeaders},
+		{"BuildVoters", env.BuildVoters},
+		{"BuildVotePlans", env.BuildVotePlans},
+		{"BuildBlock0", env.BuildBlock0},
+		{"WriteNodeConfig", env.WriteNodeConfig},
+		{"BuildApiTokens", env.BuildApiTokens},
+		{"BuildTls", env.BuildTls},
+		{"WriteStationData", env.WriteStationData},
+	}
+	for _, step := range steps {
+		if err = step.fn(); err != nil {
+			return env, fmt.Errorf("%s: %w", step.name, err)
+		}
+	}
+
+	return env, nil
+}
+
+func timeTrack(start time.Time, name string) {
+	elapsed := time.Since(start)
+	log.Printf("%s took %s", name, elapsed)
+}
+
+// cmdErr wraps err with the context and the (stderr) output of the failed command.
+func cmdErr(err error, context string, out []byte) error {
+	return fmt.Errorf("%s: %w - %s", context, err, kit.B2S(out))
+}
+
+// parseBackoff parses a restart backoff, empty means no delay.
+func parseBackoff(backoff string) (time.Duration, error) {
+	if backoff == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(backoff)
+}
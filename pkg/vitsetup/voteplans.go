@@ -0,0 +1,404 @@
+package vitsetup
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/input-output-hk/jorvit/internal/datastore"
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/input-output-hk/jorvit/internal/loader"
+	"github.com/rinor/jorcli/jcli"
+	"golang.org/x/crypto/blake2b"
+)
+
+// VotePlanProposal is the voteplan proposal definition used by jcli.
+type VotePlanProposal struct {
+	ExternalID  string `json:"external_id"`
+	Options     uint8  `json:"options"`
+	Action      string `json:"action"`
+	ChallengeID uint32 `json:"challenge_id"`
+}
+
+// VotePlan is the voteplan definition used by jcli.
+type VotePlan struct {
+	Payload                   string             `json:"payload_type"`
+	VoteStart                 ChainTime          `json:"vote_start"`
+	VoteEnd                   ChainTime          `json:"vote_end"`
+	CommitteeEnd              ChainTime          `json:"committee_end"`
+	Proposals                 []VotePlanProposal `json:"proposals"`
+	CommitteeMemberPublicKeys []string           `json:"committee_member_public_keys"` // privacy encyption keys
+	VotePlanID                string             `json:"-"`
+	Certificate               string             `json:"-"` // signed certificate
+	CertificateUnsigned       string             `json:"-"` // unsigned certificate, submitted after genesis
+	FundID                    uint64             `json:"-"`
+}
+
+func votePlansNeeded(proposalsTot int, max int) int {
+	votePlansNeeded, more := proposalsTot/max, proposalsTot%max
+	if more > 0 {
+		votePlansNeeded = votePlansNeeded + 1
+	}
+	return votePlansNeeded
+}
+
+// BuildVotePlans groups the proposals into voteplans (per fund and payload type),
+// generates the voteplans certificates and updates the proposals and funds data.
+// Privacy committee keys are generated if needed and not provided.
+func (env *Env) BuildVotePlans() error {
+	env.PrivacyPublicKeys = append([]string{}, env.Config.Committee.PrivacyPublicKeys...)
+
+	privateProposals := datastore.Filter(
+		env.Proposals.All(),
+		func(v *loader.ProposalData) bool {
+			return v.VoteType == "private"
+		},
+	)
+
+	// check if we have privacy committee members when we don't have private voteplans
+	if len(*privateProposals) == 0 && len(env.PrivacyPublicKeys) > 0 {
+		return fmt.Errorf(" %s provided, but no %s proposals found", "committee-privacy-public-key", "private")
+	}
+
+	// check we have also privacy committee members when we have private voteplans
+	if len(*privateProposals) > 0 && len(env.PrivacyPublicKeys) == 0 {
+		log.Printf("%s proposals found, but no %s provided...building one for you in %s", "private", "committee-privacy-public-key", env.VotePlanDir)
+
+		memberPK, err := env.buildCommitteeMemberKeys()
+		if err != nil {
+			return err
+		}
+		env.PrivacyPublicKeys = append(env.PrivacyPublicKeys, memberPK)
+		log.Println()
+	}
+
+	// save vote encryption key
+	if len(*privateProposals) > 0 && len(env.PrivacyPublicKeys) > 0 {
+		voteEncKeyFile := filepath.Join(env.VotePlanDir, "vote_encryption_key.pk")
+
+		voteEncKey, err := jcli.VotesEncryptingVoteKey(env.PrivacyPublicKeys, "" /* voteEncKeyFile */)
+		if err != nil {
+			return cmdErr(err, "jcli.VotesEncryptingVoteKey", voteEncKey)
+		}
+		if err = ioutil.WriteFile(voteEncKeyFile, voteEncKey, 0644); err != nil {
+			return fmt.Errorf("%s: %w", "voteEncKeyFile WRITE", err)
+		}
+		env.VoteEncKey = kit.B2S(voteEncKey)
+	}
+
+	certSignersFiles := env.signerFiles()
+	if env.Config.Vote.Block0VotePlan && len(certSignersFiles) == 0 {
+		return fmt.Errorf("no [%s] available to sign the block0 certificate(s)", "bft leader SK (secret key)")
+	}
+	if env.Config.Vote.SubmitVotePlan {
+		if err := env.checkVotePlansSubmit(); err != nil {
+			return err
+		}
+	}
+
+	env.VotePlans = make([]VotePlan, 0)
+	for _, fund := range *env.Funds.All() {
+		if err := env.buildFundVotePlans(fund, certSignersFiles); err != nil {
+			return fmt.Errorf("fund [%d]: %w", fund.FundID, err)
+		}
+	}
+
+	// index the proposals by the assigned voteplan
+	if err := env.Proposals.Reindex(); err != nil {
+		return err
+	}
+
+	log.Printf("VIT - Voteplan(s) data are dumped at (%s)", env.VotePlanDir)
+	log.Println()
+
+	return nil
+}
+
+// buildFundVotePlans builds the voteplans of a single fund, using the fund timing,
+// and appends them to the env voteplans.
+func (env *Env) buildFundVotePlans(fund *loader.FundData, certSignersFiles []string) error {
+	var (
+		votePlanProposalsMax = int(env.Config.Vote.VotePlanProposalsMax)
+		dateTimeFormat       = env.Config.TimeFormat
+		timing               = env.FundsTiming[fund.FundID]
+	)
+
+	// Proposals list per payload type
+	payloadProposals := make(map[string][]*loader.ProposalData)
+	for _, p := range *env.Proposals.All() {
+		if p.ChainVotePlan.FundID != fund.FundID {
+			continue
+		}
+		payloadProposals[p.VoteType] = append(payloadProposals[p.VoteType], p)
+	}
+
+	// keep the voteplans order (and so their IDs) stable between runs
+	payloadTypes := make([]string, 0, len(payloadProposals))
+	for pt := range payloadProposals {
+		payloadTypes = append(payloadTypes, pt)
+	}
+	sort.Strings(payloadTypes)
+
+	// Calculate nr of needed voteplans since there is a limit of proposals a plan can have (255)
+	// Taking in consideration also payload
+	vpNeeded := 0
+	for _, vpp := range payloadProposals {
+		vpNeeded += votePlansNeeded(len(vpp), votePlanProposalsMax)
+	}
+
+	votePlans := make([]VotePlan, vpNeeded)
+	fund.VotePlans = make([]loader.ChainVotePlan, vpNeeded)
+
+	jcliVotePlansCreated := 0
+	for _, pt := range payloadTypes {
+		vpi := 0
+		// Generate proposals hash and associate it to a voteplan
+		for i, proposal := range payloadProposals[pt] {
+
+			// tmp - hash the proposal (TODO: decide what to hash in production, file bytes ???)
+			externalID := blake2b.Sum256([]byte(proposal.Proposal.ID + strconv.FormatUint(proposal.InternalID, 10) + pt))
+			proposal.ChainProposal.ExternalID = hex.EncodeToString(externalID[:])
+
+			// retrieve the voteplan internal index based on the proposal index we are at
+			// taking in consideration also previous payloads voteplans created
+			vpi = (i / votePlanProposalsMax) + jcliVotePlansCreated
+
+			// Set payload once
+			if votePlans[vpi].Payload == "" {
+				votePlans[vpi].Payload = pt
+			}
+
+			// add proposal hash to the respective voteplan internal container
+			votePlans[vpi].Proposals = append(
+				votePlans[vpi].Proposals,
+				VotePlanProposal{
+					ExternalID:  proposal.ChainProposal.ExternalID,
+					Options:     uint8(len(proposal.ChainProposal.VoteOptions)),
+					Action:      proposal.VoteAction,
+					ChallengeID: proposal.ChallengeID,
+				},
+			)
+		}
+		jcliVotePlansCreated = vpi + 1 // vpi is an index so we need +1
+	}
+
+	// index the proposals by the new chain external id
+	if err := env.Proposals.Reindex(); err != nil {
+		return err
+	}
+
+	// Generate voteplan certificates and id
+	for i := range votePlans {
+
+		votePlans[i].FundID = fund.FundID
+		votePlans[i].VoteStart = timing.VoteStart
+		votePlans[i].VoteEnd = timing.VoteEnd
+		votePlans[i].CommitteeEnd = timing.CommitteeEnd
+
+		// Add committee privacy public keys if VotePlan payload is private
+		switch votePlans[i].Payload {
+		case "private":
+			votePlans[i].CommitteeMemberPublicKeys = env.PrivacyPublicKeys
+		case "public":
+			votePlans[i].CommitteeMemberPublicKeys = []string{}
+		}
+
+		stdinConfig, err := json.MarshalIndent(votePlans[i], "", " ")
+		if err != nil {
+			return fmt.Errorf("%s: %w", "json.Marshal VotePlan Config", err)
+		}
+
+		ucert, err := jcli.CertificateNewVotePlan(stdinConfig, "", "")
+		if err != nil {
+			return cmdErr(err, "CertificateNewVotePlan", ucert)
+		}
+
+		id, err := jcli.CertificateGetVotePlanID(ucert, "", "")
+		if err != nil {
+			return cmdErr(err, "CertificateGetVotePlanID", id)
+		}
+
+		votePlans[i].VotePlanID = kit.B2S(id)
+		votePlans[i].CertificateUnsigned = kit.B2S(ucert)
+
+		// Assuming that bft leaders will be part of committee signing keys
+		scert := []byte{}
+		if env.Config.Vote.Block0VotePlan {
+			scert, err = jcli.CertificateSign(ucert, certSignersFiles, "", "")
+			if err != nil {
+				return cmdErr(err, "CertificateSign", scert)
+			}
+
+			votePlans[i].Certificate = kit.B2S(scert)
+		}
+
+		vpFile := filepath.Join(env.VotePlanDir, votePlans[i].Payload+"_voteplan_"+kit.B2S(id))
+
+		// VotePlan - configuration
+		if err = ioutil.WriteFile(vpFile+".json", stdinConfig, 0644); err != nil {
+			return fmt.Errorf("%s %s: %w", "VotePlan json WRITE", kit.B2S(id), err)
+		}
+
+		// VotePlan - unsigned certificate
+		if err = ioutil.WriteFile(vpFile+".cert-unsigned", ucert, 0644); err != nil {
+			return fmt.Errorf("%s %s: %w", "VotePlan cert-unsigned WRITE", kit.B2S(id), err)
+		}
+
+		// VotePlan - signed certificate
+		if len(scert) > 0 {
+			if err = ioutil.WriteFile(vpFile+".cert-signed", scert, 0644); err != nil {
+				return fmt.Errorf("%s %s: %w", "VotePlan cert-signed WRITE", kit.B2S(id), err)
+			}
+		}
+
+		// Update Fund info with VotePlans Data
+		fund.VotePlans[i].VotePlanID = votePlans[i].VotePlanID
+		fund.VotePlans[i].VoteStart = timing.VoteStartTime.Format(dateTimeFormat)
+		fund.VotePlans[i].VoteEnd = timing.VoteEndTime.Format(dateTimeFormat)
+		fund.VotePlans[i].CommitteeEnd = timing.CommitteeEndTime.Format(dateTimeFormat)
+		fund.VotePlans[i].Payload = votePlans[i].Payload
+
+		fund.VotePlans[i].FundID = fund.FundID
+		fund.VotePlans[i].VpInternalID = strconv.Itoa(len(env.VotePlans) + 1) // unique across funds
+
+		// set chain_vote_encryption_key for the api
+		if votePlans[i].Payload == "private" {
+			fund.VotePlans[i].VoteEncryptionKey = env.VoteEncKey
+		}
+
+		// Update proposals index and voteplan
+		for pi, prop := range votePlans[i].Proposals {
+			proposal := env.Proposals.SearchExternalID(prop.ExternalID)
+
+			proposal.ChainProposal.Index = uint8(pi)
+			proposal.ChainVotePlan = &(fund.VotePlans[i])
+		}
+
+		env.VotePlans = append(env.VotePlans, votePlans[i])
+	}
+
+	//////////////////////////////////////////////
+	/* TODO: TMP - remove once/if properly defined */
+	if fund.StartTime == "" {
+		fund.StartTime = timing.VoteStartTime.Format(dateTimeFormat)
+	}
+	if fund.EndTime == "" {
+		fund.EndTime = timing.VoteEndTime.Format(dateTimeFormat)
+	}
+	if fund.VotingPowerInfo == "" {
+		fund.VotingPowerInfo = fund.StartTime
+	}
+	if fund.RewardsInfo == "" {
+		fund.RewardsInfo = timing.CommitteeEndTime.Add(7 * env.EpochDuration).Format(dateTimeFormat)
+	}
+	if fund.NextStartTime == "" {
+		fund.NextStartTime = timing.CommitteeEndTime.Add(15 * env.EpochDuration).Format(dateTimeFormat)
+	}
+	/* TODO: TMP - remove once/if properly defined */
+	//////////////////////////////////////////////
+
+	return nil
+}
+
+// buildCommitteeMemberKeys generates a single privacy committee member key pair,
+// dumping all the related files inside VotePlanDir, and returns the member PK.
+func (env *Env) buildCommitteeMemberKeys() (string, error) {
+	csr, err := jcli.VotesCRSGenerate(env.seedFor("committee_crs", 0), filepath.Join(env.VotePlanDir, "committee.csr"))
+	if err != nil {
+		return "", cmdErr(err, "jcli.VotesCRSGenerate", csr)
+	}
+
+	commSKFile := filepath.Join(env.VotePlanDir, "committee_communication_key.sk")
+	commPKFile := filepath.Join(env.VotePlanDir, "committee_communication_key.pk")
+
+	commSK, err := jcli.VotesCommitteeCommunicationKeyGenerate(env.seedFor("committee_communication", 0), commSKFile)
+	if err != nil {
+		return "", cmdErr(err, "jcli.VotesCommitteeCommunicationKeyGenerate", commSK)
+	}
+	commPK, err := jcli.VotesCommitteeCommunicationKeyToPublic(nil, commSKFile, commPKFile)
+	if err != nil {
+		return "", cmdErr(err, "jcli.VotesCommitteeCommunicationKeyToPublic", commPK)
+	}
+
+	memberSKFile := filepath.Join(env.VotePlanDir, "committee_member_key.sk")
+	memberPKFile := filepath.Join(env.VotePlanDir, "committee_member_key.pk")
+
+	memberSK, err := jcli.VotesCommitteeMemberKeyGenerate(kit.B2S(csr), 1, []string{kit.B2S(commPK)}, 0, env.seedFor("committee_member", 0), "" /* memberSKFile */)
+	if err != nil {
+		return "", cmdErr(err, "jcli.VotesCommitteeMemberKeyGenerate", memberSK)
+	}
+	memberPK, err := jcli.VotesCommitteeMemberKeyToPublic(memberSK, "", "")
+	if err != nil {
+		return "", cmdErr(err, "jcli.VotesCommitteeMemberKeyToPublic", memberPK)
+	}
+
+	if err = ioutil.WriteFile(memberSKFile, memberSK, 0644); err != nil {
+		return "", fmt.Errorf("%s: %w", "memberSKFile WRITE", err)
+	}
+	if err = ioutil.WriteFile(memberPKFile, memberPK, 0644); err != nil {
+		return "", fmt.Errorf("%s: %w", "memberPKFile WRITE", err)
+	}
+	env.CommitteeMemberSKFile = memberSKFile
+
+	return kit.B2S(memberPK), nil
+}
+
+// votePlanCertFee returns the voteplan certificate fee, the certificate one when not set.
+func (env *Env) votePlanCertFee() uint64 {
+	if env.Config.Fees.CertificateVotePlan > 0 {
+		return env.Config.Fees.CertificateVotePlan
+	}
+	return env.Config.Fees.Certificate
+}
+
+// checkVotePlansSubmit checks that the voteplans can be submitted after genesis:
+// the transactions can be signed and paid and every fund voting starts after genesis.
+func (env *Env) checkVotePlansSubmit() error {
+	if env.Config.Vote.Block0VotePlan {
+		return fmt.Errorf("[%s] and [%s] are mutually exclusive", "block0-voteplan", "submit-voteplan")
+	}
+	if err := env.checkCertTx(env.votePlanCertFee()); err != nil {
+		return fmt.Errorf("%s: %w", "submit-voteplan", err)
+	}
+	for _, ft := range env.FundsTiming {
+		if !ft.VoteStartTime.After(env.GenesisTime) {
+			return fmt.Errorf("%s: fund [%d] vote start (%s) has to be after genesis time", "submit-voteplan", ft.FundID, ft.VoteStart)
+		}
+	}
+	return nil
+}
+
+// SubmitVotePlans submits, once the node REST api is ready, the voteplans certificates
+// within transactions signed by the first BFT leader (see submitCertificate),
+// waiting for each one to be in a block, until stop is closed.
+// A voteplan has to be on chain before its vote start.
+func (env *Env) SubmitVotePlans(stop <-chan struct{}) {
+	ticker := time.NewTicker(FragmentPollInterval)
+	defer ticker.Stop()
+
+	for env.nodeReady() != nil {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+
+	for _, vp := range env.VotePlans {
+		var (
+			deadline = env.FundsTiming[vp.FundID].VoteStartTime
+			file     = filepath.Join(env.VotePlanDir, vp.Payload+"_voteplan_"+vp.VotePlanID)
+		)
+		if _, err := env.submitCertificate(vp.CertificateUnsigned, env.votePlanCertFee(), file, "VOTEPLAN", deadline, stop); err != nil {
+			log.Printf("***** VIT - VOTEPLAN: %s voteplan %s FAILED: %s", vp.Payload, vp.VotePlanID, err)
+			continue
+		}
+		log.Printf("VIT - VOTEPLAN: %s voteplan %s - on chain", vp.Payload, vp.VotePlanID)
+	}
+}
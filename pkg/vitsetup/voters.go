@@ -0,0 +1,271 @@
+package vitsetup
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gocarina/gocsv"
+	"github.com/input-output-hk/jorvit/internal/kit"
+	"github.com/rinor/jorcli/jcli"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Voter value distributions.
+const (
+	VotersFixed   = "fixed"   // every voter gets value
+	VotersUniform = "uniform" // uniform in [value, value_max]
+	VotersPareto  = "pareto"  // pareto with minimum value and shape pareto_alpha, capped at value_max
+)
+
+// Voter contains a generated voter wallet, funded on block0.
+type Voter struct {
+	Address   string `csv:"address"`
+	Value     uint64 `csv:"value"`
+	SK        string `csv:"sk"`
+	PK        string `csv:"-"`
+	SKFile    string `csv:"-"`
+	QrPayload string `csv:"-"` // hex, PIN encrypted SK
+}
+
+// voterQr is the voters_qr.csv row.
+type voterQr struct {
+	Address string `csv:"address"`
+	Pin     string `csv:"pin"`
+	Payload string `csv:"qr_payload"`
+}
+
+// QR code payload (catalyst wallet format): version | salt | nonce | chacha20poly1305(SK),
+// with the key derived from the PIN digits by PBKDF2-HMAC-SHA512.
+const (
+	qrVersion    = 1
+	qrSaltSize   = 16
+	qrIterations = 12983
+)
+
+// BuildVoters generates the voter wallets (Ed25519Extended key pairs) with the configured
+// value distribution, and writes in the working dir "voters" folder their secret keys,
+// voters.csv (address, value, sk) and voters_qr.csv (address, pin, qr_payload).
+func (env *Env) BuildVoters() error {
+	cfg := env.Config.Voters
+	if cfg.Count == 0 {
+		return nil
+	}
+
+	pin, err := qrPin(cfg.QrPin)
+	if err != nil {
+		return err
+	}
+	value, err := env.votersValue()
+	if err != nil {
+		return err
+	}
+
+	env.VotersDir = filepath.Join(env.WorkingDir, "voters")
+	if err = os.Mkdir(env.VotersDir, 0755); err != nil {
+		return fmt.Errorf("%s: %w", "votersDir", err)
+	}
+
+	env.Voters = make([]Voter, 0, cfg.Count)
+	qrs := make([]voterQr, 0, cfg.Count)
+
+	for i := 0; uint(i) < cfg.Count; i++ {
+		voterSK, err := jcli.KeyGenerate(env.seedFor("voter", i), "Ed25519Extended", "")
+		if err != nil {
+			return cmdErr(err, "jcli.KeyGenerate", voterSK)
+		}
+		voterPK, err := jcli.KeyToPublic(voterSK, "", "")
+		if err != nil {
+			return cmdErr(err, "jcli.KeyToPublic", voterPK)
+		}
+		voterACC, err := jcli.AddressAccount(kit.B2S(voterPK), "", "")
+		if err != nil {
+			return cmdErr(err, "jcli.AddressAccount", voterACC)
+		}
+		skHex, err := jcli.KeyToBytes(voterSK, "", "")
+		if err != nil {
+			return cmdErr(err, "jcli.KeyToBytes", skHex)
+		}
+		skBytes, err := hex.DecodeString(kit.B2S(skHex))
+		if err != nil {
+			return fmt.Errorf("%s: %w", "voter SK hex", err)
+		}
+		payload, err := qrPayload(skBytes, pin)
+		if err != nil {
+			return fmt.Errorf("%s: %w", "voter QR payload", err)
+		}
+
+		skFile := filepath.Join(env.VotersDir, strconv.Itoa(i)+"_voter_secret.key")
+		if err = ioutil.WriteFile(skFile, voterSK, 0600); err != nil {
+			return err
+		}
+
+		voter := Voter{
+			Address:   kit.B2S(voterACC),
+			Value:     value(),
+			SK:        kit.B2S(voterSK),
+			PK:        kit.B2S(voterPK),
+			SKFile:    skFile,
+			QrPayload: payload,
+		}
+		env.Voters = append(env.Voters, voter)
+		qrs = append(qrs, voterQr{Address: voter.Address, Pin: cfg.QrPin, Payload: payload})
+	}
+
+	env.VotersFile = filepath.Join(env.VotersDir, "voters.csv")
+	if err = marshalCsvFile(&env.Voters, env.VotersFile); err != nil {
+		return fmt.Errorf("%s: %w", "voters CSV", err)
+	}
+	env.VotersQrFile = filepath.Join(env.VotersDir, "voters_qr.csv")
+	if err = marshalCsvFile(&qrs, env.VotersQrFile); err != nil {
+		return fmt.Errorf("%s: %w", "voters QR CSV", err)
+	}
+
+	log.Printf("VIT - VOTERS: %d %s wallets - %s", len(env.Voters), cfg.Distribution, env.VotersFile)
+
+	return nil
+}
+
+// LoadVoters returns the voter wallets generated in votersDir (the working dir "voters" folder).
+func LoadVoters(votersDir string) ([]Voter, error) {
+	data, err := ioutil.ReadFile(filepath.Join(votersDir, "voters.csv"))
+	if err != nil {
+		return nil, err
+	}
+	voters := make([]Voter, 0)
+	if err = gocsv.UnmarshalBytes(data, &voters); err != nil {
+		return nil, fmt.Errorf("%s: %w", "voters CSV", err)
+	}
+	for i := range voters {
+		voters[i].SKFile = filepath.Join(votersDir, strconv.Itoa(i)+"_voter_secret.key")
+		if _, err = os.Stat(voters[i].SKFile); err != nil {
+			return nil, err
+		}
+	}
+	return voters, nil
+}
+
+// votersValue returns the generator of the voters value, based on the configured distribution.
+// The values are reproducible in reproducible mode.
+func (env *Env) votersValue() (func() uint64, error) {
+	cfg := env.Config.Voters
+	if cfg.Value == 0 {
+		return nil, fmt.Errorf("voters value has to be > 0")
+	}
+
+	seed := time.Now().UnixNano()
+	if env.Config.IsReproducible() {
+		sum, _ := hex.DecodeString(env.deriveSeed("voters_value", 0))
+		seed = int64(binary.BigEndian.Uint64(sum))
+	}
+	rnd := mrand.New(mrand.NewSource(seed))
+
+	// keep the total supply within uint64
+	supplyMax := uint64(math.MaxUint64) / uint64(cfg.Count)
+	if cfg.Value > supplyMax {
+		return nil, fmt.Errorf("voters value [%d] above the max value [%d]", cfg.Value, supplyMax)
+	}
+	// value_max applies to the random distributions only
+	valueMax := supplyMax
+	if cfg.ValueMax > 0 && cfg.ValueMax < valueMax {
+		valueMax = cfg.ValueMax
+	}
+	if cfg.Distribution != VotersFixed && cfg.Value > valueMax {
+		return nil, fmt.Errorf("voters value [%d] above the max value [%d]", cfg.Value, valueMax)
+	}
+
+	switch cfg.Distribution {
+	case VotersFixed:
+		return func() uint64 { return cfg.Value }, nil
+
+	case VotersUniform:
+		if cfg.ValueMax == 0 {
+			return nil, fmt.Errorf("%s distribution needs the voters max value", VotersUniform)
+		}
+		span := valueMax - cfg.Value
+		return func() uint64 {
+			if span == math.MaxUint64 {
+				return rnd.Uint64()
+			}
+			return cfg.Value + uint64n(rnd, span+1)
+		}, nil
+
+	case VotersPareto:
+		if cfg.ParetoAlpha <= 0 {
+			return nil, fmt.Errorf("%s distribution needs pareto alpha > 0", VotersPareto)
+		}
+		return func() uint64 {
+			v := float64(cfg.Value) * math.Pow(1-rnd.Float64(), -1/cfg.ParetoAlpha)
+			if v >= float64(valueMax) {
+				return valueMax
+			}
+			return uint64(v)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown voters distribution [%s], one of %v", cfg.Distribution, []string{VotersFixed, VotersUniform, VotersPareto})
+	}
+}
+
+// uint64n returns a uniform random value in [0, n).
+func uint64n(rnd *mrand.Rand, n uint64) uint64 {
+	if n <= math.MaxInt64 {
+		return uint64(rnd.Int63n(int64(n)))
+	}
+	for {
+		if v := rnd.Uint64(); v < n {
+			return v
+		}
+	}
+}
+
+// qrPin returns the PIN digits used as QR payload password.
+func qrPin(pin string) ([]byte, error) {
+	if len(pin) != 4 {
+		return nil, fmt.Errorf("voters QR PIN has to be 4 digits, got [%s]", pin)
+	}
+	digits := make([]byte, len(pin))
+	for i := range pin {
+		if pin[i] < '0' || pin[i] > '9' {
+			return nil, fmt.Errorf("voters QR PIN has to be 4 digits, got [%s]", pin)
+		}
+		digits[i] = pin[i] - '0'
+	}
+	return digits, nil
+}
+
+// qrPayload returns the hex encoded QR code payload of sk, encrypted with pin.
+func qrPayload(sk []byte, pin []byte) (string, error) {
+	salt := make([]byte, qrSaltSize)
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	aead, err := chacha20poly1305.New(pbkdf2.Key(pin, salt, qrIterations, chacha20poly1305.KeySize, sha512.New))
+	if err != nil {
+		return "", err
+	}
+
+	payload := make([]byte, 0, 1+len(salt)+len(nonce)+len(sk)+aead.Overhead())
+	payload = append(payload, qrVersion)
+	payload = append(payload, salt...)
+	payload = append(payload, nonce...)
+	payload = aead.Seal(payload, nonce, sk, nil)
+
+	return hex.EncodeToString(payload), nil
+}
@@ -0,0 +1,66 @@
+package vitsetup
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// WatchInterval is the assets files polling interval used by StartServices.
+var WatchInterval = 2 * time.Second
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampOf(filename string) fileStamp {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// WatchAssets polls the proposals and fund files and, when changed, reloads
+// their off chain data (titles, summaries, urls, ...) in the stores served by the proxy,
+// without regenerating block0. It returns once stop is closed.
+func (env *Env) WatchAssets(interval time.Duration, stop <-chan struct{}) {
+	files := []struct {
+		name   string
+		reload func(filename string) error
+	}{
+		{env.Config.Assets.Proposals, env.Proposals.Reload},
+		{env.Config.Assets.Fund, env.Funds.Reload},
+	}
+
+	last := make([]fileStamp, len(files))
+	for i := range files {
+		last[i] = stampOf(files[i].name)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		for i := range files {
+			stamp := stampOf(files[i].name)
+			if stamp == last[i] {
+				continue
+			}
+			last[i] = stamp
+
+			if err := files[i].reload(files[i].name); err != nil {
+				log.Printf("***** %s reload FAILED, keeping current data: %s", files[i].name, err)
+				continue
+			}
+			log.Printf("%s reloaded", files[i].name)
+		}
+	}
+}
@@ -75,7 +75,7 @@ func (vstation *Vstation) BuildCmdArg() []string {
 	}
 
 	if vstation.EnableApiTokens {
-		arg = append(arg, "--enable_api_tokens")
+		arg = append(arg, "--enable-api-tokens")
 	}
 
 	if vstation.Address != "" {
@@ -105,6 +105,13 @@ func (vstation *Vstation) BuildCmdArg() []string {
 		arg = append(arg, "--allowed-origins", strings.Join(vstation.Cors.AllowedOrigins, ";"))
 	}
 
+	if vstation.Tls.CertFile != "" {
+		arg = append(arg, "--cert-file", vstation.Tls.CertFile)
+	}
+	if vstation.Tls.PrivKeyFile != "" {
+		arg = append(arg, "--priv-key-file", vstation.Tls.PrivKeyFile)
+	}
+
 	return arg
 }
 